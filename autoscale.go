@@ -0,0 +1,91 @@
+package htask
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAutoscaleWait = 100 * time.Millisecond
+	defaultAutoscaleIdle = time.Minute
+)
+
+// AutoscalePolicy changes number of workers between Min and Max automatically. zero Max disables it.
+// workers grow when due tasks wait for workers longer than Wait,
+// and shrink one by one while some workers are idle for Idle.
+// Min <= 0 means 1, Wait 0 means 100ms and Idle 0 means 1 minute.
+type AutoscalePolicy struct {
+	Min  int
+	Max  int
+	Wait time.Duration
+	Idle time.Duration
+}
+
+func (p AutoscalePolicy) withDefault() AutoscalePolicy {
+	if p.Min <= 0 {
+		p.Min = 1
+	}
+	if p.Max < p.Min {
+		p.Max = p.Min
+	}
+	if p.Wait <= 0 {
+		p.Wait = defaultAutoscaleWait
+	}
+	if p.Idle <= 0 {
+		p.Idle = defaultAutoscaleIdle
+	}
+	return p
+}
+
+// clamp returns workers bounded by Min and Max.
+func (p AutoscalePolicy) clamp(workers int) int {
+	if workers < p.Min {
+		return p.Min
+	} else if workers > p.Max {
+		return p.Max
+	}
+	return workers
+}
+
+// autoscale checks Stats every p.Wait and changes workers.
+func (c *Scheduler) autoscale(wg *sync.WaitGroup, p AutoscalePolicy) {
+	defer wg.Done()
+	timer := c.clock.NewTimer(p.Wait)
+	defer timer.Stop()
+	idleSince := c.clock.Now()
+	for {
+		select {
+		case <-c.chClose:
+			return
+		case <-timer.C():
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			return
+		}
+		now := c.clock.Now()
+		workers := stats.Workers
+		// tasks of named queues do not use default workers
+		busy := int(atomic.LoadInt64(&c.counters.busy))
+		switch {
+		case stats.Paused:
+			// tasks wait for Resume, not for workers
+			idleSince = now
+		case stats.MaxWait > p.Wait && workers < p.Max:
+			// add workers for all waiting tasks
+			workers = p.clamp(workers + stats.Due)
+		case stats.Due > 0 || busy >= workers:
+			idleSince = now
+		case now.Sub(idleSince) >= p.Idle && workers > p.Min:
+			workers--
+		}
+		if workers != stats.Workers {
+			if c.ChangeWorkers(workers) != nil {
+				return
+			}
+			idleSince = now
+		}
+		timer.Reset(p.Wait)
+	}
+}
@@ -0,0 +1,56 @@
+package htask
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Autoscale(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	policy := AutoscalePolicy{Min: 1, Max: 3, Wait: 10 * time.Millisecond, Idle: time.Second}
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Clock: clock, Autoscale: policy})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	waitWorkers := func(expected int, step time.Duration) {
+		var stats Stats
+		for i := 0; i < 1000; i++ {
+			if stats, _ = scheduler.Stats(); stats.Workers == expected {
+				return
+			}
+			clock.Advance(step)
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("Workers = %v expected %v", stats.Workers, expected)
+	}
+	if stats, _ := scheduler.Stats(); stats.Workers != 1 {
+		t.Fatalf("initial Workers = %v expected Min", stats.Workers)
+	}
+
+	chRelease := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		scheduler.Set(nil, clock.Now(), func(_ time.Time) {
+			<-chRelease
+		})
+	}
+	// grows up to Max while tasks are waiting
+	waitWorkers(3, policy.Wait)
+	if stats, _ := scheduler.Stats(); stats.Due != 2 || stats.MaxWait <= policy.Wait {
+		t.Errorf("Due = %v, MaxWait = %v expected 2 tasks waiting", stats.Due, stats.MaxWait)
+	}
+	close(chRelease)
+
+	// shrinks down to Min after idle
+	waitWorkers(1, policy.Idle)
+	for i := 0; i < 5; i++ {
+		clock.Advance(policy.Idle)
+		time.Sleep(time.Millisecond)
+	}
+	if stats, _ := scheduler.Stats(); stats.Workers != 1 {
+		t.Errorf("Workers = %v shrinks below Min", stats.Workers)
+	}
+}
@@ -0,0 +1,78 @@
+package htask
+
+import (
+	"time"
+)
+
+// TaskSpec is a task passed to SetMany. arguments are the same as Set.
+type TaskSpec struct {
+	Cancel <-chan struct{}
+	Time   time.Time
+	Task   func(time.Time)
+}
+
+type batch struct {
+	jobs []*job
+	// unique rejects batch if any job has the same ID as a pending job.
+	unique   bool
+	chResult chan<- batchResult
+}
+
+// batchResult is the result of batch. first n jobs of batch are handled by scheduler and
+// dropped are jobs among them discarded by Option.Overflow. n == 0 means batch is rejected by OverflowError
+// and n == -1 means batch is rejected because of duplicated ID.
+type batchResult struct {
+	n       int
+	dropped []*job
+}
+
+// SetMany enqueue tasks to scheduler heap queue at once.
+// all tasks are sent to scheduler in one message and timer is reset once.
+// if any spec is invalid, no task is enqueued. tasks already cancelled are ignored.
+// if heap is full, SetMany follows Option.Overflow. with OverflowError, ErrMax is returned
+// and no task is enqueued unless heap has space for all tasks.
+func (c *Scheduler) SetMany(specs []TaskSpec) error {
+	jobs := make([]*job, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Time.IsZero() {
+			return ErrInvalidTime
+		} else if spec.Task == nil {
+			return ErrInvalidTask
+		}
+		select {
+		case <-spec.Cancel:
+			continue
+		default:
+		}
+		jobs = append(jobs, &job{c: c, chCancel: spec.Cancel, t: spec.Time, task: spec.Task})
+	}
+	_, err := c.setMany(jobs, false)
+	return err
+}
+
+// setMany sends jobs to scheduler and returns jobs which are not enqueued because of error
+// or dropped by Option.Overflow. dropped jobs are not error.
+// if unique is true, jobs are rejected with ErrDuplicateID if any ID is used by pending jobs.
+func (c *Scheduler) setMany(jobs []*job, unique bool) ([]*job, error) {
+	var dropped []*job
+	for len(jobs) > 0 {
+		chResult := make(chan batchResult, 1)
+		select {
+		case <-c.chClose:
+			return append(dropped, jobs...), ErrClosed
+		case c.chBatch <- batch{jobs: jobs, unique: unique, chResult: chResult}:
+		case <-c.chFull:
+			return append(dropped, jobs...), ErrMax
+		}
+		r := <-chResult
+		if r.n < 0 {
+			return append(dropped, jobs...), ErrDuplicateID
+		} else if r.n == 0 {
+			return append(dropped, jobs...), ErrMax
+		}
+		dropped = append(dropped, r.dropped...)
+		// rest of jobs wait until heap has space with OverflowBlock
+		jobs = jobs[r.n:]
+	}
+	return dropped, nil
+}
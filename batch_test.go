@@ -0,0 +1,134 @@
+package htask
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_SetMany(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+
+	const n = 1000
+	chResult := make(chan time.Time, n+10)
+	newTask := func(at time.Time) func(time.Time) {
+		return func(_ time.Time) { chResult <- at }
+	}
+	start := clock.Now()
+	for i := 0; i < 10; i++ {
+		at := start.Add(time.Duration(rand.Intn(n)+1) * time.Second)
+		scheduler.Set(nil, at, newTask(at))
+	}
+	specs := make([]TaskSpec, n)
+	for i := range specs {
+		at := start.Add(time.Duration(rand.Intn(n)+1) * time.Second)
+		specs[i] = TaskSpec{Time: at, Task: newTask(at)}
+	}
+	chCancel := make(chan struct{})
+	close(chCancel)
+	specs = append(specs, TaskSpec{Cancel: chCancel, Time: start, Task: newTask(start)})
+	if err := scheduler.SetMany(specs); err != nil {
+		t.Fatal(err)
+	}
+	if l := scheduler.Len(); l != n+10 {
+		t.Errorf("Len = %v expected %v", l, n+10)
+	}
+
+	invalid := []TaskSpec{{Time: start, Task: newTask(start)}, {Time: start}}
+	if err := scheduler.SetMany(invalid); err != ErrInvalidTask {
+		t.Errorf("SetMany invalid task : %v expected %v", err, ErrInvalidTask)
+	}
+	invalid[1] = TaskSpec{Task: newTask(start)}
+	if err := scheduler.SetMany(invalid); err != ErrInvalidTime {
+		t.Errorf("SetMany invalid time : %v expected %v", err, ErrInvalidTime)
+	}
+
+	clock.Advance(n * time.Second)
+	var last time.Time
+	for i := 0; i < n+10; i++ {
+		select {
+		case at := <-chResult:
+			if at.Before(last) {
+				t.Errorf("task of %v executed after %v", at, last)
+			}
+			last = at
+		case <-time.After(time.Second):
+			t.Fatalf("only %v tasks executed", i)
+		}
+	}
+
+	scheduler.Close()
+	wg.Wait()
+	if err := scheduler.SetMany(specs); err != ErrClosed {
+		t.Errorf("SetMany to closed scheduler : %v expected %v", err, ErrClosed)
+	}
+}
+
+func TestScheduler_SetManyOverflow(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	task := func(_ time.Time) {}
+	specs := func(at time.Time, task func(time.Time), n int) []TaskSpec {
+		specs := make([]TaskSpec, n)
+		for i := range specs {
+			specs[i] = TaskSpec{Time: at, Task: task}
+		}
+		return specs
+	}
+
+	t.Run("error", func(t *testing.T) {
+		var wg sync.WaitGroup
+		scheduler := NewSchedulerWithOption(&wg, Option{MaxPending: 10, Overflow: OverflowError})
+		defer func() {
+			scheduler.Close()
+			wg.Wait()
+		}()
+		if err := scheduler.SetMany(specs(later, task, 5)); err != nil {
+			t.Fatal(err)
+		}
+		if err := scheduler.SetMany(specs(later, task, 6)); err != ErrMax {
+			t.Errorf("SetMany overflow : %v expected %v", err, ErrMax)
+		}
+		if l := scheduler.Len(); l != 5 {
+			t.Errorf("Len = %v expected 5", l)
+		}
+		if err := scheduler.SetMany(specs(later, task, 5)); err != nil {
+			t.Fatal(err)
+		}
+		if err := scheduler.SetMany(specs(later, task, 1)); err != ErrMax {
+			t.Errorf("SetMany to full heap : %v expected %v", err, ErrMax)
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		var wg sync.WaitGroup
+		scheduler := NewSchedulerWithOption(&wg, Option{MaxPending: 10, Overflow: OverflowDropNewest})
+		defer func() {
+			scheduler.Close()
+			wg.Wait()
+		}()
+		if err := scheduler.SetMany(specs(later, task, 15)); err != nil {
+			t.Fatal(err)
+		}
+		if stats, _ := scheduler.Stats(); stats.Pending != 10 || stats.Dropped != 5 {
+			t.Errorf("Pending = %v, Dropped = %v expected 10, 5", stats.Pending, stats.Dropped)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		var wg sync.WaitGroup
+		scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, MaxPending: 10, Overflow: OverflowBlock})
+		defer func() {
+			scheduler.Close()
+			wg.Wait()
+		}()
+		var executed sync.WaitGroup
+		executed.Add(25)
+		if err := scheduler.SetMany(specs(time.Now(), func(_ time.Time) { executed.Done() }, 25)); err != nil {
+			t.Fatal(err)
+		}
+		executed.Wait()
+	})
+}
@@ -10,6 +10,84 @@ import (
 )
 
 func BenchmarkScheduler_Set(b *testing.B) {
+	benchmarkSet(b, htask.Option{})
+}
+
+func BenchmarkScheduler_SetTimingWheel(b *testing.B) {
+	benchmarkSet(b, htask.Option{WheelTick: time.Millisecond})
+}
+
+func BenchmarkScheduler_SetMany(b *testing.B) {
+	const batch = 1000
+	specs := make([]htask.TaskSpec, b.N)
+	start := time.Now().Add(time.Hour)
+	task := func(_ time.Time) {}
+	for i := range specs {
+		specs[i] = htask.TaskSpec{Time: start.Add(time.Duration(rand.Int63n(int64(time.Hour)))), Task: task}
+	}
+
+	var wg sync.WaitGroup
+
+	s := htask.NewScheduler(&wg, 0)
+
+	defer func() {
+		b.StopTimer()
+		s.Close()
+		wg.Wait()
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += batch {
+		end := i + batch
+		if end > b.N {
+			end = b.N
+		}
+		if err := s.SetMany(specs[i:end]); err != nil {
+			b.Errorf("error occurred : %v", err)
+		}
+	}
+}
+
+func BenchmarkScheduler_Dispatch(b *testing.B) {
+	benchmarkDispatch(b, htask.Option{Workers: 4})
+}
+
+func BenchmarkScheduler_DispatchTimingWheel(b *testing.B) {
+	benchmarkDispatch(b, htask.Option{Workers: 4, WheelTick: time.Millisecond})
+}
+
+func BenchmarkScheduler_SetParallel(b *testing.B) {
+	var wg sync.WaitGroup
+	s := htask.NewScheduler(&wg, 0)
+	benchmarkSetParallel(b, s.Set)
+	s.Close()
+	wg.Wait()
+}
+
+func BenchmarkShardedScheduler_SetParallel(b *testing.B) {
+	var wg sync.WaitGroup
+	s := htask.NewShardedScheduler(&wg, 0, htask.Option{})
+	benchmarkSetParallel(b, s.Set)
+	s.Close()
+	wg.Wait()
+}
+
+func benchmarkSetParallel(b *testing.B, set func(chCancel <-chan struct{}, t time.Time, task func(time.Time)) error) {
+	start := time.Now().Add(time.Hour)
+	task := func(_ time.Time) {}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			if err := set(nil, start.Add(time.Duration(r.Int63n(int64(time.Hour)))), task); err != nil {
+				b.Errorf("error occurred : %v", err)
+			}
+		}
+	})
+	b.StopTimer()
+}
+
+func benchmarkSet(b *testing.B, option htask.Option) {
 	times := make([]time.Time, b.N)
 	times[0] = time.Now().Add(time.Hour)
 	for i := 1; i < b.N; i++ {
@@ -23,7 +101,37 @@ func BenchmarkScheduler_Set(b *testing.B) {
 
 	var wg sync.WaitGroup
 
-	s := htask.NewScheduler(&wg, 0)
+	s := htask.NewSchedulerWithOption(&wg, option)
+
+	defer func() {
+		b.StopTimer()
+		s.Close()
+		wg.Wait()
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Set(nil, times[i], task); err != nil {
+			b.Errorf("error occurred : %v", err)
+		}
+	}
+}
+
+// benchmarkDispatch measures time to set tasks due within 100ms and execute all of them.
+func benchmarkDispatch(b *testing.B, option htask.Option) {
+	start := time.Now()
+	times := make([]time.Time, b.N)
+	for i := range times {
+		times[i] = start.Add(time.Duration(rand.Int63n(int64(100 * time.Millisecond))))
+	}
+
+	var executed sync.WaitGroup
+	executed.Add(b.N)
+	task := func(_ time.Time) { executed.Done() }
+
+	var wg sync.WaitGroup
+
+	s := htask.NewSchedulerWithOption(&wg, option)
 
 	defer func() {
 		b.StopTimer()
@@ -37,4 +145,5 @@ func BenchmarkScheduler_Set(b *testing.B) {
 			b.Errorf("error occurred : %v", err)
 		}
 	}
+	executed.Wait()
 }
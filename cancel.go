@@ -0,0 +1,88 @@
+package htask
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// cancelGroup is pending jobs which share the same cancel channel.
+type cancelGroup struct {
+	jobs map[*job]struct{}
+	// stop stops watching the cancel channel.
+	stop func()
+}
+
+// watchCancel registers job to be removed from heap when its cancel channel is closed.
+// each distinct cancel channel is watched once.
+func (s *scheduleState) watchCancel(j *job) {
+	if j.chCancel == nil {
+		return
+	}
+	g, ok := s.cancels[j.chCancel]
+	if !ok {
+		g = &cancelGroup{jobs: make(map[*job]struct{}), stop: s.watch(j)}
+		s.cancels[j.chCancel] = g
+	}
+	g.jobs[j] = struct{}{}
+}
+
+// unwatchCancel unregisters job. watching is stopped when no job uses the cancel channel.
+func (s *scheduleState) unwatchCancel(j *job) {
+	if j.chCancel == nil {
+		return
+	}
+	g, ok := s.cancels[j.chCancel]
+	if !ok {
+		return
+	}
+	delete(g.jobs, j)
+	if len(g.jobs) == 0 {
+		g.stop()
+		delete(s.cancels, j.chCancel)
+	}
+}
+
+// cancel removes all jobs which is cancelled by chCancel.
+func (s *scheduleState) cancel(chCancel <-chan struct{}) {
+	g, ok := s.cancels[chCancel]
+	if !ok {
+		return
+	}
+	for j := range g.jobs {
+		s.remove(j)
+		atomic.AddUint64(&j.c.countersOf(j).cancelled, 1)
+	}
+}
+
+// watchCancel notifies scheduler when cancel channel of j is closed and returns the function to stop watching.
+// context of the job is watched by context.AfterFunc which starts no goroutine until the context is done.
+// other cancel channel is watched by a goroutine.
+func (c *Scheduler) watchCancel(j *job) func() {
+	chCancel := j.chCancel
+	notify := func(stop <-chan struct{}) {
+		select {
+		case c.chCancelled <- chCancel:
+		case <-stop:
+		case <-c.chClose:
+		}
+	}
+	stop := make(chan struct{})
+	if j.ctx != nil {
+		stopFunc := context.AfterFunc(j.ctx, func() { notify(stop) })
+		return func() {
+			stopFunc()
+			close(stop)
+		}
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		select {
+		case <-chCancel:
+			notify(stop)
+		case <-stop:
+		case <-c.chClose:
+		}
+	}()
+	return func() { close(stop) }
+}
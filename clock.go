@@ -0,0 +1,132 @@
+package htask
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is source of current time and timers used by Scheduler.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the interface of time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// RealClock is Clock using time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// FakeClock is Clock whose time is advanced manually. it is useful for tests.
+// timers fire when the clock is advanced past their time.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+// NewFakeClock creates FakeClock starts from now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, timers: make(map[*fakeTimer]struct{})}
+}
+
+// Now returns current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates Timer fires after d on the clock.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.reset(t, d)
+	c.mu.Unlock()
+	return t
+}
+
+// Advance moves the clock forward by d and fires expired timers in order of their time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var expired []*fakeTimer
+	for t := range c.timers {
+		if !t.when.After(c.now) {
+			expired = append(expired, t)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].when.Before(expired[j].when)
+	})
+	for _, t := range expired {
+		c.fire(t, t.when)
+	}
+}
+
+func (c *FakeClock) reset(t *fakeTimer, d time.Duration) bool {
+	_, active := c.timers[t]
+	if d <= 0 {
+		c.fire(t, c.now)
+		return active
+	}
+	t.when = c.now.Add(d)
+	c.timers[t] = struct{}{}
+	return active
+}
+
+func (c *FakeClock) fire(t *fakeTimer, now time.Time) {
+	delete(c.timers, t)
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	c     chan time.Time
+	when  time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.reset(t, d)
+}
@@ -0,0 +1,99 @@
+package htask
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	t1 := clock.NewTimer(time.Second)
+	t2 := clock.NewTimer(time.Minute)
+	t3 := clock.NewTimer(time.Hour)
+
+	clock.Advance(time.Second - 1)
+	select {
+	case <-t1.C():
+		t.Errorf("timer fired before its time")
+	default:
+	}
+	clock.Advance(1)
+	select {
+	case now := <-t1.C():
+		if !now.Equal(start.Add(time.Second)) {
+			t.Errorf("fired time = %v expected %v", now, start.Add(time.Second))
+		}
+	default:
+		t.Errorf("timer not fired")
+	}
+	if t1.Stop() {
+		t.Errorf("Stop returns true for fired timer")
+	}
+	if !t2.Stop() {
+		t.Errorf("Stop returns false for active timer")
+	}
+	if !t3.Reset(time.Second) {
+		t.Errorf("Reset returns false for active timer")
+	}
+	if !clock.Now().Equal(start.Add(time.Second)) {
+		t.Errorf("Now = %v expected %v", clock.Now(), start.Add(time.Second))
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case <-t2.C():
+		t.Errorf("stopped timer fired")
+	default:
+	}
+	select {
+	case now := <-t3.C():
+		if !now.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("fired time = %v expected %v", now, start.Add(2*time.Second))
+		}
+	default:
+		t.Errorf("reset timer not fired")
+	}
+
+	t1.Reset(0)
+	select {
+	case <-t1.C():
+	default:
+		t.Errorf("timer reset by 0 not fired")
+	}
+}
+
+func TestScheduler_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chResult := make(chan time.Time, 10)
+	task := func(ts time.Time) { chResult <- ts }
+	first := clock.Now().Add(24 * time.Hour)
+	scheduler.Set(nil, first.Add(time.Hour), task)
+	scheduler.Set(nil, first, task)
+
+	clock.Advance(23 * time.Hour)
+	if stats, _ := scheduler.Stats(); stats.Executed != 0 || stats.Pending != 2 {
+		t.Errorf("stats before time = %+v", stats)
+	}
+
+	for _, expected := range []time.Time{first, first.Add(time.Hour)} {
+		clock.Advance(time.Hour)
+		select {
+		case ts := <-chResult:
+			if !ts.Equal(expected) {
+				t.Errorf("executed at %v expected %v", ts, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("task not executed")
+		}
+	}
+}
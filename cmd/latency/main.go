@@ -15,11 +15,13 @@ var (
 	n        = flag.Int("n", 1000000, "total task number")
 	workers  = flag.Int("worker", 0, "number of workers goroutine")
 	interval = flag.Int64("interval", 1000, "task schedule interval (ns)")
+	tick     = flag.Duration("tick", 0, "tick of timing wheel. 0 means min heap")
+	maxConc  = flag.Int("concurrency", 0, "max running tasks when worker is 0. 0 means no limit")
 )
 
 func main() {
 	flag.Parse()
-	run(*n, *workers, time.Duration(*interval))
+	run(*n, *workers, time.Duration(*interval), *tick, *maxConc)
 }
 
 type job struct {
@@ -37,11 +39,11 @@ type result struct {
 
 var results []result
 
-func run(total int, workers int, interval time.Duration) {
+func run(total int, workers int, interval time.Duration, tick time.Duration, maxConc int) {
 	results = make([]result, total)
 	start := time.Now()
 	var wg sync.WaitGroup
-	s := htask.NewScheduler(&wg, workers)
+	s := htask.NewSchedulerWithOption(&wg, htask.Option{Workers: workers, WheelTick: tick, MaxConcurrency: maxConc})
 	defer func() {
 		s.Close()
 		wg.Wait()
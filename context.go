@@ -0,0 +1,116 @@
+package htask
+
+import (
+	"context"
+	"time"
+)
+
+// TaskInfo is metadata of task passed to task set by SetContext.
+type TaskInfo struct {
+	// ID is Handle.ID of the task.
+	ID uint64
+	// Scheduled is the time task is scheduled at.
+	Scheduled time.Time
+	// Fired is the time scheduler dispatched the task.
+	Fired time.Time
+	// Attempt is the number of this execution starts from 1. it is incremented by retry.
+	Attempt int
+	// Late is how long the task started after Scheduled.
+	Late time.Duration
+	// Wait is how long the task waited for a worker or Option.MaxConcurrency after Fired.
+	Wait time.Duration
+}
+
+// TaskOption can accept zero value.
+// Timeout limits execution time of the task. 0 means Option.TaskTimeout is used.
+// Retry decides when the task is retried after it returns error.
+// Priority orders tasks which are already due. higher priority task is dispatched first.
+// Misfire decides what happens to the task which starts later than MisfireThreshold after its time.
+// MisfireThreshold 0 means DefaultMisfireThreshold.
+// Queue is the name of queue defined by Option.Queues which executes the task. empty means default workers.
+type TaskOption struct {
+	Timeout          time.Duration
+	Retry            RetryPolicy
+	Priority         int
+	Misfire          MisfirePolicy
+	MisfireThreshold time.Duration
+	Queue            string
+}
+
+// SetContext enqueue new task to scheduler heap queue and returns Handle of the task.
+// task is cancelled when ctx is done before executed.
+// context passed to task is cancelled when ctx is done, the task is cancelled by Handle,
+// the task times out or scheduler is closed. error returned by task can be got by Handle.Err.
+func (c *Scheduler) SetContext(ctx context.Context, t time.Time, task func(context.Context, TaskInfo) error) (*Handle, error) {
+	return c.SetContextWithOption(ctx, t, task, TaskOption{})
+}
+
+// SetContextWithOption is SetContext configured by option.
+func (c *Scheduler) SetContextWithOption(ctx context.Context, t time.Time, task func(context.Context, TaskInfo) error, option TaskOption) (*Handle, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q, err := c.queue(option.Queue)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handle{s: c, id: c.newID(), t: t}
+	j := &job{chCancel: ctx.Done(), t: t, ctx: ctx, ctxTask: task, h: h, q: q}
+	j.apply(option)
+	if err := c.set(j); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// apply sets option to the job.
+func (j *job) apply(option TaskOption) {
+	j.timeout = option.Timeout
+	j.priority = option.Priority
+	j.misfire = option.Misfire
+	j.misfireThreshold = option.MisfireThreshold
+	if option.Retry.MaxAttempts > 1 {
+		retry := option.Retry
+		j.retry = &retry
+	}
+}
+
+func (j job) info() TaskInfo {
+	info := TaskInfo{Scheduled: j.t, Fired: j.fired, Attempt: j.attempt + 1}
+	if j.started.After(j.t) {
+		info.Late = j.started.Sub(j.t)
+	}
+	if !j.fired.IsZero() && j.started.After(j.fired) {
+		info.Wait = j.started.Sub(j.fired)
+	}
+	if j.h != nil {
+		info.ID = j.h.id
+	}
+	return info
+}
+
+// runContext returns context passed to task which is cancelled when scheduler is aborted.
+func (c *Scheduler) runContext(j job) (context.Context, context.CancelFunc) {
+	if j.ctxTask == nil {
+		return nil, func() {}
+	}
+	ctx, cancel := context.WithCancel(j.ctx)
+	// run is called in worker or goroutine counted to c.running then Add is safe.
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		select {
+		case <-c.chAbort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// abort cancels context of running tasks.
+func (c *Scheduler) abort() {
+	c.abortOnce.Do(func() {
+		close(c.chAbort)
+	})
+}
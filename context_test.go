@@ -0,0 +1,178 @@
+package htask
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_SetContext(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	t.Run("info and error", func(t *testing.T) {
+		errTask := errors.New("task error")
+		chInfo := make(chan TaskInfo, 1)
+		at := time.Now().Add(time.Millisecond * 10)
+		h, err := scheduler.SetContext(context.Background(), at, func(ctx context.Context, info TaskInfo) error {
+			chInfo <- info
+			return errTask
+		})
+		if err != nil {
+			t.Fatalf("SetContext error : %v", err)
+		}
+		select {
+		case info := <-chInfo:
+			if info.ID != h.ID() || !info.Scheduled.Equal(at) || info.Fired.Before(at) {
+				t.Errorf("info = %+v", info)
+			}
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("task not executed")
+		}
+		for i := 0; i < 100 && h.Status() == StateRunning; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		if s := h.Status(); s != StateFailed {
+			t.Errorf("status = %v expected %v", s, StateFailed)
+		}
+		if err := h.Err(); err != errTask {
+			t.Errorf("Err = %v expected %v", err, errTask)
+		}
+	})
+
+	t.Run("cancel pending", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		h, _ := scheduler.SetContext(ctx, time.Now().Add(time.Millisecond*10), func(ctx context.Context, info TaskInfo) error {
+			t.Error("cancelled task executed")
+			return nil
+		})
+		cancel()
+		time.Sleep(time.Millisecond * 30)
+		if s := h.Status(); s != StateCancelled {
+			t.Errorf("status = %v expected %v", s, StateCancelled)
+		}
+		if _, err := scheduler.SetContext(ctx, time.Now(), func(ctx context.Context, info TaskInfo) error { return nil }); err != ErrTaskCancelled {
+			t.Errorf("SetContext with cancelled context error : %v expected %v", err, ErrTaskCancelled)
+		}
+	})
+
+	t.Run("cancel running", func(t *testing.T) {
+		chStarted, chErr := make(chan struct{}), make(chan error, 1)
+		h, _ := scheduler.SetContext(context.Background(), time.Now(), func(ctx context.Context, info TaskInfo) error {
+			close(chStarted)
+			<-ctx.Done()
+			chErr <- ctx.Err()
+			return ctx.Err()
+		})
+		<-chStarted
+		if err := h.Cancel(); err != ErrTaskStarted {
+			t.Errorf("Cancel running task error : %v expected %v", err, ErrTaskStarted)
+		}
+		select {
+		case err := <-chErr:
+			if err != context.Canceled {
+				t.Errorf("context error = %v expected %v", err, context.Canceled)
+			}
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("context of running task is not cancelled")
+		}
+	})
+
+	if _, err := scheduler.SetContext(context.Background(), time.Now(), nil); err != ErrInvalidTask {
+		t.Errorf("nil task error : %v expected %v", err, ErrInvalidTask)
+	}
+}
+
+func TestScheduler_SetContextClose(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 0)
+
+	chStarted, chErr := make(chan struct{}), make(chan error, 1)
+	scheduler.SetContext(context.Background(), time.Now(), func(ctx context.Context, info TaskInfo) error {
+		close(chStarted)
+		<-ctx.Done()
+		chErr <- ctx.Err()
+		return nil
+	})
+	<-chStarted
+	scheduler.Close()
+	select {
+	case err := <-chErr:
+		if err != context.Canceled {
+			t.Errorf("context error = %v expected %v", err, context.Canceled)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("context of running task is not cancelled by Close")
+	}
+	wg.Wait()
+}
+
+func TestScheduler_Priority(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	// occupy the only worker
+	chStarted, chRelease := make(chan struct{}), make(chan struct{})
+	scheduler.Set(nil, clock.Now(), func(_ time.Time) {
+		close(chStarted)
+		<-chRelease
+	})
+	<-chStarted
+
+	chResult := make(chan int, 10)
+	set := func(at time.Time, priority, id int) {
+		task := func(ctx context.Context, info TaskInfo) error {
+			chResult <- id
+			return nil
+		}
+		if _, err := scheduler.SetContextWithOption(context.Background(), at, task, TaskOption{Priority: priority}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := clock.Now().Add(time.Second)
+	set(due, 0, 0)
+	set(due, 3, 1)
+	set(due, 1, 2)
+	set(due, 3, 3)
+	set(due.Add(-time.Millisecond), 2, 4)
+	// future job keeps time ordering even though it has the highest priority
+	set(due.Add(time.Second), 10, 5)
+
+	clock.Advance(time.Second)
+	close(chRelease)
+	for _, expected := range []int{1, 3, 4, 2, 0} {
+		select {
+		case id := <-chResult:
+			if id != expected {
+				t.Errorf("executed %v expected %v", id, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("task %v not executed", expected)
+		}
+	}
+	select {
+	case id := <-chResult:
+		t.Fatalf("future task %v executed", id)
+	case <-time.After(time.Millisecond * 10):
+	}
+	clock.Advance(time.Second)
+	select {
+	case id := <-chResult:
+		if id != 5 {
+			t.Errorf("executed %v expected %v", id, 5)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("future task not executed")
+	}
+}
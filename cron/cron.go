@@ -16,15 +16,20 @@ var (
 // Cron is wrapper of htask.Scheduler with human friendly interface.
 type Cron struct {
 	*htask.Scheduler
-	loc *time.Location
+	loc   *time.Location
+	clock htask.Clock
 }
 
 // Option can accept zero value.
 // Workers is number of worker goroutine.
 // Location is used Every(x).Day().At(hour, minute, sec, nsec) to identify `At` time.
+// PanicHandler is called when task panics. see htask.Option.
+// Clock is used to get current time and timer. if nil, htask.RealClock is used.
 type Option struct {
-	Workers  int
-	Location *time.Location
+	Workers      int
+	Location     *time.Location
+	PanicHandler htask.PanicHandler
+	Clock        htask.Clock
 }
 
 // NewCron creates Cron.
@@ -32,9 +37,17 @@ func NewCron(wg *sync.WaitGroup, option Option) *Cron {
 	if option.Location == nil {
 		option.Location = time.Local
 	}
+	if option.Clock == nil {
+		option.Clock = htask.RealClock
+	}
 	return &Cron{
-		Scheduler: htask.NewScheduler(wg, option.Workers),
-		loc:       option.Location,
+		Scheduler: htask.NewSchedulerWithOption(wg, htask.Option{
+			Workers:      option.Workers,
+			PanicHandler: option.PanicHandler,
+			Clock:        option.Clock,
+		}),
+		loc:   option.Location,
+		clock: option.Clock,
 	}
 }
 
@@ -54,6 +67,8 @@ type JobBuilder struct {
 	num      time.Duration
 	interval time.Duration
 	from     time.Time
+	misfire  htask.MisfirePolicy
+	late     time.Duration
 	err      error
 }
 
@@ -107,7 +122,7 @@ func (j JobBuilder) At(times ...int) JobBuilder {
 			return j
 		}
 	}
-	now := time.Now()
+	now := j.cron.clock.Now()
 	j.from = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, sec, nsec, j.cron.loc)
 	if j.from.Before(now) {
 		j.from = j.from.Add(24 * time.Hour)
@@ -121,6 +136,17 @@ func (j JobBuilder) From(from time.Time) JobBuilder {
 	return j
 }
 
+// Misfire decides what happens to firings later than threshold. see htask.MisfirePolicy.
+// by default late job runs for each missed firing. threshold 0 means htask.DefaultMisfireThreshold.
+func (j JobBuilder) Misfire(policy htask.MisfirePolicy, threshold time.Duration) JobBuilder {
+	if threshold <= 0 {
+		threshold = htask.DefaultMisfireThreshold
+	}
+	j.misfire = policy
+	j.late = threshold
+	return j
+}
+
 // Run starts Job and returns cancel func.
 func (j JobBuilder) Run(task func()) (cancel func(), err error) {
 	if j.err != nil {
@@ -128,13 +154,15 @@ func (j JobBuilder) Run(task func()) (cancel func(), err error) {
 	}
 	chCancel := make(chan struct{})
 	if j.from.IsZero() {
-		j.from = time.Now()
+		j.from = j.cron.clock.Now()
 	}
 	job := intervalJob{
 		cron:     j.cron,
 		chCancel: chCancel,
 		next:     j.from,
 		interval: j.interval,
+		misfire:  j.misfire,
+		late:     j.late,
 		task:     task,
 	}
 	if err = job.cron.Set(chCancel, job.next, job.callback); err != nil {
@@ -154,16 +182,27 @@ type intervalJob struct {
 	chCancel chan struct{}
 	next     time.Time
 	interval time.Duration
+	misfire  htask.MisfirePolicy
+	late     time.Duration // firing later than late is misfired.
 	task     func()
 }
 
-func (j *intervalJob) nextTime() {
+func (j *intervalJob) nextTime(now time.Time, misfired bool) {
 	j.next = j.next.Add(j.interval)
+	if misfired && j.misfire == htask.MisfireCoalesce && j.interval > 0 && !j.next.After(now) {
+		// skip all firings already missed
+		j.next = j.next.Add((now.Sub(j.next)/j.interval + 1) * j.interval)
+	}
 }
 
 func (j *intervalJob) callback(_ time.Time) {
-	j.nextTime()
+	now := j.cron.clock.Now()
+	misfired := j.misfire != htask.MisfireRun && now.Sub(j.next) > j.late
+	j.nextTime(now, misfired)
 	j.cron.Set(j.chCancel, j.next, j.callback)
+	if misfired && j.misfire == htask.MisfireSkip {
+		return
+	}
 	j.task()
 }
 
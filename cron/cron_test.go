@@ -5,6 +5,8 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/kawasin73/htask"
 )
 
 func TestCron_Every(t *testing.T) {
@@ -126,3 +128,81 @@ func TestCron_Once(t *testing.T) {
 	case <-time.After(120 * time.Millisecond):
 	}
 }
+
+func TestCron_FakeClock(t *testing.T) {
+	clock := htask.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	cron := NewCron(&wg, Option{
+		Workers:  1,
+		Location: time.UTC,
+		Clock:    clock,
+	})
+	defer func() {
+		cron.Close()
+		wg.Wait()
+	}()
+
+	chResult := make(chan time.Time)
+	cron.Every(1).Day().At(1, 2, 3).Run(func() {
+		chResult <- clock.Now()
+	})
+
+	expected := time.Date(2020, 1, 1, 1, 2, 3, 0, time.UTC)
+	clock.Advance(time.Hour)
+	select {
+	case now := <-chResult:
+		t.Fatalf("executed before time at %v", now)
+	default:
+	}
+	clock.Advance(time.Hour)
+	// one month
+	for i := 0; i < 31; i++ {
+		select {
+		case now := <-chResult:
+			if now.Before(expected) || !now.Before(expected.Add(24*time.Hour)) {
+				t.Errorf("executed at %v expected after %v", now, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("day %v not executed", i)
+		}
+		expected = expected.Add(24 * time.Hour)
+		clock.Advance(24 * time.Hour)
+	}
+}
+
+func TestCron_Misfire(t *testing.T) {
+	clock := htask.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	cron := NewCron(&wg, Option{Workers: 1, Clock: clock})
+	defer func() {
+		cron.Close()
+		wg.Wait()
+	}()
+
+	chResult := make(chan time.Time)
+	cron.Every(1).Minute().From(clock.Now().Add(time.Minute)).Misfire(htask.MisfireCoalesce, 0).Run(func() {
+		chResult <- clock.Now()
+	})
+
+	// suspended for 10 minutes
+	clock.Advance(10 * time.Minute)
+	select {
+	case <-chResult:
+	case <-time.After(time.Second):
+		t.Fatal("late job not executed")
+	}
+	select {
+	case now := <-chResult:
+		t.Fatalf("missed firings are not coalesced, executed again at %v", now)
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(time.Minute)
+	select {
+	case now := <-chResult:
+		if expected := time.Date(2020, 1, 1, 0, 11, 0, 0, time.UTC); !now.Equal(expected) {
+			t.Errorf("executed at %v expected %v", now, expected)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("next job not executed")
+	}
+}
@@ -0,0 +1,101 @@
+package htask
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errors
+var (
+	ErrDuplicateID = errors.New("task of the same ID already exists")
+)
+
+// Export writes pending tasks set by SetNamed to w as versioned JSON which Import reads.
+// tasks are taken from heap at once and kept in Scheduler.
+// if Scheduler is already closed then returns ErrClosed.
+func (c *Scheduler) Export(w io.Writer) error {
+	tasks, err := c.List()
+	if err != nil {
+		return err
+	} else if tasks == nil {
+		tasks = []NamedTask{}
+	}
+	return json.NewEncoder(w).Encode(snapshot{Version: storeVersion, Records: tasks})
+}
+
+// Import enqueue tasks written by Export and returns number of imported tasks.
+// tasks discarded by Option.Overflow are not counted.
+// time, option, attempt and ID of tasks are preserved and IDs of tasks set later are bigger than them.
+// names of all tasks must be registered before Import, otherwise ErrUnknownTask is returned
+// and no task is imported. if any ID is used by a pending task or a task in Store then returns
+// ErrDuplicateID and no task is imported. if format version is not supported then returns ErrStoreVersion.
+func (c *Scheduler) Import(r io.Reader) (int, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, err
+	} else if snap.Version != storeVersion {
+		return 0, ErrStoreVersion
+	}
+	jobs := make([]*job, len(snap.Records))
+	ids := make(map[uint64]bool, len(snap.Records))
+	var maxID uint64
+	for i, task := range snap.Records {
+		if task.ID == 0 {
+			return 0, ErrInvalidTask
+		} else if ids[task.ID] {
+			return 0, ErrDuplicateID
+		} else if task.Time.IsZero() {
+			return 0, ErrInvalidTime
+		} else if c.handler(task.Name) == nil {
+			return 0, ErrUnknownTask
+		} else if _, err := c.queue(task.Option.Queue); err != nil {
+			return 0, err
+		}
+		j := c.namedJob(task.ID, task.Name, task.Payload, task.Time, task.Option)
+		j.c = c
+		j.attempt = task.Attempt
+		jobs[i] = j
+		ids[task.ID] = true
+		if task.ID > maxID {
+			maxID = task.ID
+		}
+	}
+	// new IDs never conflict with imported IDs after this
+	c.useID(maxID)
+	for i, j := range jobs {
+		if err := j.saveNew(); err != nil {
+			for _, j := range jobs[:i] {
+				j.forget()
+			}
+			return 0, err
+		}
+	}
+	rest, err := c.setMany(jobs, true)
+	for _, j := range rest {
+		j.forget()
+	}
+	return len(jobs) - len(rest), err
+}
+
+// hasID returns true if any of jobs has the same ID as a pending job.
+func (s *scheduleState) hasID(jobs []*job) bool {
+	ids := make(map[uint64]bool, len(jobs))
+	for _, j := range jobs {
+		if j.h != nil {
+			ids[j.h.id] = true
+		}
+	}
+	found := false
+	f := func(j *job) {
+		if j.h != nil && ids[j.h.id] {
+			found = true
+		}
+	}
+	s.queue.each(f)
+	s.due.each(f)
+	for _, q := range s.queues {
+		q.due.each(f)
+	}
+	return found
+}
@@ -0,0 +1,93 @@
+package htask
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Import(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	noop := func(ctx context.Context, payload []byte, info TaskInfo) error { return nil }
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	src := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer src.Close()
+	src.Register("noop", noop)
+	src.Register("send-email", noop)
+	later := clock.Now().Add(time.Hour)
+	src.SetNamed("send-email", []byte("to:a"), later.Add(time.Minute))
+	src.Set(nil, later, func(_ time.Time) {})
+	src.SetNamedWithOption("noop", nil, later, TaskOption{Priority: 2, Retry: RetryPolicy{MaxAttempts: 3}})
+	expected, _ := src.List()
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := src.Len(); n != 3 {
+		t.Errorf("Len after Export = %v expected 3", n)
+	}
+
+	exported := append([]byte(nil), buf.Bytes()...)
+
+	dst := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer dst.Close()
+	dst.Register("noop", noop)
+	if n, err := dst.Import(bytes.NewReader(buf.Bytes())); n != 0 || err != ErrUnknownTask {
+		t.Errorf("Import unregistered task : %v, %v expected %v", n, err, ErrUnknownTask)
+	}
+	if n := dst.Len(); n != 0 {
+		t.Errorf("Len = %v expected 0", n)
+	}
+	if _, err := dst.Import(strings.NewReader(`{"version":0,"records":[]}`)); err != ErrStoreVersion {
+		t.Errorf("Import unknown version : %v expected %v", err, ErrStoreVersion)
+	}
+
+	dst.Register("send-email", noop)
+	if n, err := dst.Import(&buf); n != 2 || err != nil {
+		t.Fatalf("Import : %v, %v expected 2", n, err)
+	}
+	tasks, err := dst.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("imported %+v expected %+v", tasks, expected)
+	}
+	h, _ := dst.SetNamed("noop", nil, later)
+	for _, task := range tasks {
+		if h.ID() <= task.ID {
+			t.Errorf("new ID %v is not bigger than imported ID %v", h.ID(), task.ID)
+		}
+	}
+
+	// tasks dropped by overflow are not imported
+	for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowEvictLatest} {
+		small := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock, MaxPending: 1, Overflow: policy})
+		small.Register("noop", noop)
+		small.Register("send-email", noop)
+		if n, err := small.Import(bytes.NewReader(exported)); n != 1 || err != nil {
+			t.Errorf("Import to small heap with policy %v : %v, %v expected 1", policy, n, err)
+		}
+		small.Close()
+	}
+
+	// IDs conflict with tasks of scheduler
+	busy := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer busy.Close()
+	busy.Register("noop", noop)
+	busy.Register("send-email", noop)
+	busy.SetTask(later, func(_ time.Time) {})
+	if n, err := busy.Import(bytes.NewReader(exported)); n != 0 || err != ErrDuplicateID {
+		t.Errorf("Import duplicated ID : %v, %v expected %v", n, err, ErrDuplicateID)
+	}
+	if n := busy.Len(); n != 1 {
+		t.Errorf("Len = %v expected 1", n)
+	}
+}
@@ -0,0 +1,183 @@
+package htask
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TaskState is state of task referred by Handle.
+type TaskState int
+
+// task states
+const (
+	// StatePending means task is waiting in heap.
+	StatePending TaskState = iota
+	// StateRunning means task is executing.
+	StateRunning
+	// StateDone means task have been executed.
+	StateDone
+	// StateCancelled means task is cancelled or discarded before executed.
+	StateCancelled
+	// StateFailed means task set by SetContext have returned error or task have timed out.
+	StateFailed
+)
+
+func (s TaskState) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateRunning:
+		return "running"
+	case StateDone:
+		return "done"
+	case StateCancelled:
+		return "cancelled"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Handle refers to a task set by Scheduler.SetTask or Scheduler.SetContext.
+// Handle is safe for concurrent use.
+type Handle struct {
+	s     *Scheduler
+	id    uint64
+	mu    sync.Mutex
+	t     time.Time
+	state TaskState
+	err   error
+	// cancelRun cancels context of running task set by SetContext.
+	cancelRun context.CancelFunc
+	// stopped is true if running task is cancelled. the task is not retried.
+	stopped bool
+
+	// j is the job in heap. it is accessed only by scheduler goroutine.
+	j *job
+}
+
+// ID returns unique ID of task in Scheduler.
+func (h *Handle) ID() uint64 {
+	return h.id
+}
+
+// Time returns the time task is scheduled at.
+func (h *Handle) Time() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.t
+}
+
+// Status returns current state of task.
+func (h *Handle) Status() TaskState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+// Err returns error returned by task set by SetContext, ErrTaskTimeout or ErrMisfired.
+func (h *Handle) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Cancel cancels pending task and removes it from heap.
+// if task is already started then returns ErrTaskStarted,
+// if task is already cancelled then returns ErrTaskCancelled.
+// context of running task set by SetContext is cancelled even if it is started.
+func (h *Handle) Cancel() error {
+	h.mu.Lock()
+	if err := h.pendingErr(); err != nil {
+		if h.state == StateRunning && h.cancelRun != nil {
+			h.cancelRun()
+			h.stopped = true
+		}
+		h.mu.Unlock()
+		return err
+	}
+	h.state = StateCancelled
+	h.mu.Unlock()
+
+	select {
+	case <-h.s.chClose:
+	case h.s.chRemove <- h:
+	}
+	return nil
+}
+
+// Reschedule moves pending task to t. see Scheduler.Reschedule.
+func (h *Handle) Reschedule(t time.Time) error {
+	return h.s.Reschedule(h, t)
+}
+
+func (h *Handle) pendingErr() error {
+	switch h.state {
+	case StatePending:
+		return nil
+	case StateCancelled:
+		return ErrTaskCancelled
+	default:
+		return ErrTaskStarted
+	}
+}
+
+// start marks task running if task is pending.
+func (h *Handle) start(cancel context.CancelFunc) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state != StatePending {
+		return false
+	}
+	h.state = StateRunning
+	h.cancelRun = cancel
+	return true
+}
+
+// retry marks running task pending again at t. returns false if task is cancelled while running.
+func (h *Handle) retry(t time.Time, err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return false
+	}
+	h.state = StatePending
+	h.t = t
+	h.err = err
+	h.cancelRun = nil
+	return true
+}
+
+func (h *Handle) finish(err error) {
+	h.mu.Lock()
+	h.state = StateDone
+	if err != nil {
+		h.state = StateFailed
+	}
+	h.err = err
+	h.cancelRun = nil
+	h.mu.Unlock()
+}
+
+// skip marks pending task cancelled with err. returns false if task is already cancelled.
+func (h *Handle) skip(err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state != StatePending {
+		return false
+	}
+	h.state = StateCancelled
+	h.err = err
+	return true
+}
+
+// discard marks task cancelled if task is pending.
+func (h *Handle) discard() {
+	h.mu.Lock()
+	if h.state == StatePending {
+		h.state = StateCancelled
+	}
+	h.mu.Unlock()
+}
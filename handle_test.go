@@ -0,0 +1,150 @@
+package htask
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandle(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chResult := make(chan int, 10)
+	newTask := func(i int) func(time.Time) {
+		return func(_ time.Time) { chResult <- i }
+	}
+	now := time.Now()
+
+	h0, err := scheduler.SetTask(now.Add(time.Millisecond*20), newTask(0))
+	if err != nil {
+		t.Fatalf("SetTask error : %v", err)
+	}
+	h1, _ := scheduler.SetTask(now.Add(time.Millisecond*30), newTask(1))
+	h2, _ := scheduler.SetTask(now.Add(time.Millisecond*40), newTask(2))
+	h3, _ := scheduler.SetTask(now.Add(time.Hour), newTask(3))
+
+	if h0.ID() == h1.ID() || h1.ID() == h2.ID() {
+		t.Errorf("ID is not unique : %v, %v, %v", h0.ID(), h1.ID(), h2.ID())
+	}
+	if s := h0.Status(); s != StatePending {
+		t.Errorf("status = %v expected %v", s, StatePending)
+	}
+
+	if err := h1.Cancel(); err != nil {
+		t.Errorf("Cancel error : %v", err)
+	}
+	if err := h1.Cancel(); err != ErrTaskCancelled {
+		t.Errorf("Cancel twice error : %v expected %v", err, ErrTaskCancelled)
+	}
+	if err := h1.Reschedule(now); err != ErrTaskCancelled {
+		t.Errorf("Reschedule cancelled error : %v expected %v", err, ErrTaskCancelled)
+	}
+
+	// 3 is moved before 2 and 0 is moved after 2.
+	if err := h3.Reschedule(now.Add(time.Millisecond * 30)); err != nil {
+		t.Errorf("Reschedule error : %v", err)
+	}
+	if err := h0.Reschedule(now.Add(time.Millisecond * 50)); err != nil {
+		t.Errorf("Reschedule error : %v", err)
+	}
+	if tm := h3.Time(); !tm.Equal(now.Add(time.Millisecond * 30)) {
+		t.Errorf("rescheduled time = %v expected %v", tm, now.Add(time.Millisecond*30))
+	}
+	if err := h0.Reschedule(time.Time{}); err != ErrInvalidTime {
+		t.Errorf("Reschedule zero time error : %v expected %v", err, ErrInvalidTime)
+	}
+
+	for _, i := range []int{3, 2, 0} {
+		select {
+		case r := <-chResult:
+			if r != i {
+				t.Errorf("result received but not euqal %v != %v", r, i)
+			}
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("task not executed : ", i)
+		}
+	}
+	select {
+	case r := <-chResult:
+		t.Errorf("unexpected result : %v", r)
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	if s := h0.Status(); s != StateDone {
+		t.Errorf("status = %v expected %v", s, StateDone)
+	}
+	if s := h1.Status(); s != StateCancelled {
+		t.Errorf("status = %v expected %v", s, StateCancelled)
+	}
+	if err := h0.Cancel(); err != ErrTaskStarted {
+		t.Errorf("Cancel done task error : %v expected %v", err, ErrTaskStarted)
+	}
+	if err := h0.Reschedule(now); err != ErrTaskStarted {
+		t.Errorf("Reschedule done task error : %v expected %v", err, ErrTaskStarted)
+	}
+}
+
+func TestScheduler_Reschedule(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chResult := make(chan time.Time, 10)
+	h, _ := scheduler.SetTask(time.Now().Add(time.Millisecond*20), func(ts time.Time) { chResult <- ts })
+
+	// debounce
+	var last time.Time
+	for i := 0; i < 5; i++ {
+		last = time.Now().Add(time.Millisecond * 20)
+		if err := scheduler.Reschedule(h, last); err != nil {
+			t.Fatalf("Reschedule error : %v", err)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	select {
+	case ts := <-chResult:
+		if ts.Before(last) {
+			t.Errorf("task executed at %v before rescheduled time %v", ts, last)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("rescheduled task not executed")
+	}
+	select {
+	case ts := <-chResult:
+		t.Errorf("task executed twice at %v", ts)
+	case <-time.After(time.Millisecond * 30):
+	}
+
+	chStarted, chBlock := make(chan struct{}), make(chan struct{})
+	h, _ = scheduler.SetTask(time.Now(), func(_ time.Time) {
+		close(chStarted)
+		<-chBlock
+	})
+	<-chStarted
+	if err := h.Reschedule(time.Now().Add(time.Hour)); err != ErrTaskStarted {
+		t.Errorf("Reschedule running task error : %v expected %v", err, ErrTaskStarted)
+	}
+	if s := h.Status(); s != StateRunning {
+		t.Errorf("status = %v expected %v", s, StateRunning)
+	}
+	close(chBlock)
+
+	var other sync.WaitGroup
+	otherScheduler := NewScheduler(&other, 0)
+	defer func() {
+		otherScheduler.Close()
+		other.Wait()
+	}()
+	if err := otherScheduler.Reschedule(h, time.Now()); err != ErrInvalidTask {
+		t.Errorf("Reschedule other scheduler task error : %v expected %v", err, ErrInvalidTask)
+	}
+}
@@ -3,34 +3,79 @@ package htask
 import (
 	"container/heap"
 	"errors"
+	"time"
 )
 
-type jobHeap []job
+type jobHeap []*job
 
 // Len is length of jobHeap
 func (h *jobHeap) Len() int {
 	return len(*h)
 }
 
-// Less means job j is newer than i
+// Less means job i is earlier than j. jobs of the same time is ordered by insertion.
 func (h *jobHeap) Less(i, j int) bool {
-	return !(*h)[i].t.After((*h)[j].t)
+	a, b := (*h)[i], (*h)[j]
+	if !a.t.Equal(b.t) {
+		return a.t.Before(b.t)
+	}
+	return a.seq < b.seq
 }
 
-// Swap swaps the elements with indexes i and j.
+// Swap swaps the elements with indexes i and j and tracks their index.
 func (h *jobHeap) Swap(i, j int) {
 	(*h)[i], (*h)[j] = (*h)[j], (*h)[i]
+	(*h)[i].index = i
+	(*h)[j].index = j
 }
 
 // Push adds x to tail
 func (h *jobHeap) Push(x interface{}) {
-	*h = append(*h, x.(job))
+	j := x.(*job)
+	j.index = len(*h)
+	*h = append(*h, j)
 }
 
 // Pop removes x from head
 func (h *jobHeap) Pop() (x interface{}) {
-	x, *h = (*h)[len(*h)-1], (*h)[:len(*h)-1]
-	return
+	old := *h
+	j := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	j.index = -1
+	return j
+}
+
+// priorityHeap is jobHeap ordered by priority. jobs of the same priority is ordered by time.
+type priorityHeap jobHeap
+
+// Len is length of priorityHeap
+func (h *priorityHeap) Len() int {
+	return len(*h)
+}
+
+// Less means job i has higher priority than j.
+func (h *priorityHeap) Less(i, j int) bool {
+	a, b := (*h)[i], (*h)[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return (*jobHeap)(h).Less(i, j)
+}
+
+// Swap swaps the elements with indexes i and j and tracks their index.
+func (h *priorityHeap) Swap(i, j int) {
+	(*jobHeap)(h).Swap(i, j)
+}
+
+// Push adds x to tail
+func (h *priorityHeap) Push(x interface{}) {
+	(*jobHeap)(h).Push(x)
+}
+
+// Pop removes x from head
+func (h *priorityHeap) Pop() (x interface{}) {
+	return (*jobHeap)(h).Pop()
 }
 
 // errors
@@ -39,8 +84,9 @@ var (
 )
 
 type minHeap struct {
-	heap jobHeap
-	max  int
+	heap       jobHeap
+	max        int
+	byPriority bool
 }
 
 func newMinHeap(max int) *minHeap {
@@ -52,28 +98,131 @@ func newMinHeap(max int) *minHeap {
 	return h
 }
 
-func (h *minHeap) add(j job) error {
-	if h.max > 0 && len(h.heap) >= h.max {
+// newPriorityHeap creates minHeap which pops the job of the highest priority first.
+func newPriorityHeap(max int) *minHeap {
+	h := newMinHeap(max)
+	h.byPriority = true
+	return h
+}
+
+func (h *minHeap) container() heap.Interface {
+	if h.byPriority {
+		return (*priorityHeap)(&h.heap)
+	}
+	return &h.heap
+}
+
+func (h *minHeap) add(j *job) error {
+	if h.full() {
 		return ErrMax
 	}
-	heap.Push(&h.heap, j)
+	heap.Push(h.container(), j)
+	return nil
+}
+
+// addMany adds jobs at once. heap is rebuilt in O(n) if jobs are more than existing jobs.
+func (h *minHeap) addMany(jobs []*job) error {
+	if h.max > 0 && len(h.heap)+len(jobs) > h.max {
+		return ErrMax
+	}
+	if len(jobs) < len(h.heap) {
+		for _, j := range jobs {
+			heap.Push(h.container(), j)
+		}
+		return nil
+	}
+	for _, j := range jobs {
+		j.index = len(h.heap)
+		h.heap = append(h.heap, j)
+	}
+	heap.Init(h.container())
 	return nil
 }
 
-func (h *minHeap) pop() job {
+func (h *minHeap) pop() *job {
 	if len(h.heap) == 0 {
-		return job{}
+		return nil
 	}
-	return heap.Pop(&h.heap).(job)
+	return heap.Pop(h.container()).(*job)
 }
 
-func (h *minHeap) peek() job {
+func (h *minHeap) peek() *job {
 	if len(h.heap) == 0 {
-		return job{}
+		return nil
 	}
 	return h.heap[0]
 }
 
+// next returns the time of the earliest job.
+func (h *minHeap) next() (time.Time, bool) {
+	if len(h.heap) == 0 {
+		return time.Time{}, false
+	}
+	return h.heap[0].t, true
+}
+
+// popDue pops the earliest job if it is scheduled until now.
+func (h *minHeap) popDue(now time.Time) *job {
+	if len(h.heap) == 0 || h.heap[0].t.After(now) {
+		return nil
+	}
+	return h.pop()
+}
+
+// clear removes all jobs and returns them in popped order.
+func (h *minHeap) clear() []*job {
+	jobs := make([]*job, 0, len(h.heap))
+	for j := h.pop(); j != nil; j = h.pop() {
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+func (h *minHeap) each(f func(j *job)) {
+	for _, j := range h.heap {
+		f(j)
+	}
+}
+
 func (h *minHeap) size() int {
 	return len(h.heap)
 }
+
+func (h *minHeap) full() bool {
+	return h.max > 0 && len(h.heap) >= h.max
+}
+
+// latest returns the job which has the furthest time.
+// the furthest job is always one of the leaves if heap is ordered by time.
+func (h *minHeap) latest() *job {
+	if len(h.heap) == 0 {
+		return nil
+	}
+	idx := len(h.heap) / 2
+	if h.byPriority {
+		idx = 0
+	}
+	for i := idx + 1; i < len(h.heap); i++ {
+		if h.heap[idx].t.Before(h.heap[i].t) {
+			idx = i
+		}
+	}
+	return h.heap[idx]
+}
+
+// remove removes j from heap in O(log n). j is ignored if it is not in heap.
+func (h *minHeap) remove(j *job) bool {
+	if j.index < 0 || j.index >= len(h.heap) || h.heap[j.index] != j {
+		return false
+	}
+	heap.Remove(h.container(), j.index)
+	return true
+}
+
+// fix re-establishes heap ordering after time of j is changed.
+func (h *minHeap) fix(j *job) {
+	if j.index < 0 || j.index >= len(h.heap) || h.heap[j.index] != j {
+		return
+	}
+	heap.Fix(h.container(), j.index)
+}
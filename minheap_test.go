@@ -14,11 +14,11 @@ func TestMinHeap(t *testing.T) {
 
 	t.Run("no limit min heap", func(t *testing.T) {
 		h := newMinHeap(0)
-		h.add(job{t: times[5]})
-		h.add(job{t: times[4]})
-		h.add(job{t: times[6]})
-		h.add(job{t: times[3]})
-		h.add(job{t: times[4]})
+		h.add(&job{t: times[5]})
+		h.add(&job{t: times[4]})
+		h.add(&job{t: times[6]})
+		h.add(&job{t: times[3]})
+		h.add(&job{t: times[4]})
 
 		result := []int{3, 4, 4, 5, 6}
 		for _, i := range result {
@@ -32,10 +32,10 @@ func TestMinHeap(t *testing.T) {
 		if h.size() != 0 {
 			t.Errorf("expect empty but size = %v", h.size())
 		}
-		if peek := h.peek(); !peek.t.IsZero() {
+		if peek := h.peek(); peek != nil {
 			t.Errorf("empty peek = %v", peek.t)
 		}
-		if pop := h.peek(); !pop.t.IsZero() {
+		if pop := h.pop(); pop != nil {
 			t.Errorf("empty pop = %v", pop.t)
 		}
 	})
@@ -43,13 +43,13 @@ func TestMinHeap(t *testing.T) {
 	t.Run("limited min heap", func(t *testing.T) {
 		max := 5
 		h := newMinHeap(max)
-		h.add(job{t: times[5]})
-		h.add(job{t: times[4]})
-		h.add(job{t: times[6]})
-		h.add(job{t: times[3]})
-		h.add(job{t: times[4]})
+		h.add(&job{t: times[5]})
+		h.add(&job{t: times[4]})
+		h.add(&job{t: times[6]})
+		h.add(&job{t: times[3]})
+		h.add(&job{t: times[4]})
 
-		if err := h.add(job{t: times[1]}); err != ErrMax {
+		if err := h.add(&job{t: times[1]}); err != ErrMax {
 			t.Errorf("max add expected error: %v, but %v", ErrMax, err)
 		}
 
@@ -65,11 +65,158 @@ func TestMinHeap(t *testing.T) {
 		if h.size() != 0 {
 			t.Errorf("expect empty but size = %v", h.size())
 		}
-		if peek := h.peek(); !peek.t.IsZero() {
+		if peek := h.peek(); peek != nil {
 			t.Errorf("empty peek = %v", peek.t)
 		}
-		if pop := h.peek(); !pop.t.IsZero() {
+		if pop := h.pop(); pop != nil {
 			t.Errorf("empty pop = %v", pop.t)
 		}
 	})
+
+	t.Run("remove latest", func(t *testing.T) {
+		h := newMinHeap(0)
+		if j := h.latest(); j != nil {
+			t.Errorf("empty latest = %v", j.t)
+		}
+		h.add(&job{t: times[5]})
+		h.add(&job{t: times[4]})
+		h.add(&job{t: times[8]})
+		h.add(&job{t: times[3]})
+		h.add(&job{t: times[6]})
+
+		for _, i := range []int{8, 6, 5, 4, 3} {
+			latest := h.latest()
+			if !latest.t.Equal(times[i]) {
+				t.Errorf("latest = %v expected %v", latest.t, times[i])
+			}
+			if !h.remove(latest) {
+				t.Errorf("remove latest failed")
+			}
+		}
+		if h.size() != 0 {
+			t.Errorf("expect empty but size = %v", h.size())
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		h := newMinHeap(0)
+		jobs := make([]*job, 10)
+		for i := range jobs {
+			jobs[i] = &job{t: times[(i*7)%10]}
+			h.add(jobs[i])
+		}
+		for i := 0; i < 10; i += 2 {
+			if !h.remove(jobs[i]) {
+				t.Errorf("remove %v failed", i)
+			}
+			if jobs[i].index != -1 {
+				t.Errorf("removed job index = %v", jobs[i].index)
+			}
+		}
+		if h.remove(jobs[0]) {
+			t.Errorf("removed job is removed again")
+		}
+		if h.size() != 5 {
+			t.Errorf("size = %v expected 5", h.size())
+		}
+		for _, j := range h.heap {
+			if h.heap[j.index] != j {
+				t.Errorf("index is broken %v", j.index)
+			}
+		}
+		var last time.Time
+		for h.size() > 0 {
+			j := h.pop()
+			if j.t.Before(last) {
+				t.Errorf("heap order is broken %v < %v", j.t, last)
+			}
+			last = j.t
+		}
+	})
+
+	t.Run("fix", func(t *testing.T) {
+		h := newMinHeap(0)
+		jobs := make([]*job, 5)
+		for i := range jobs {
+			jobs[i] = &job{t: times[i+2]}
+			h.add(jobs[i])
+		}
+		jobs[4].t = times[0]
+		h.fix(jobs[4])
+		jobs[0].t = times[9]
+		h.fix(jobs[0])
+
+		for _, i := range []int{4, 1, 2, 3, 0} {
+			if pop := h.pop(); pop != jobs[i] {
+				t.Errorf("pop = %v expected %v", pop.t, jobs[i].t)
+			}
+		}
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		h := newMinHeap(0)
+		jobs := make([]*job, 1000)
+		for i := range jobs {
+			// 0 ~ 9 have the same time
+			jobs[i] = &job{t: times[i%10], seq: uint64(i)}
+			h.add(jobs[i])
+		}
+		for i := 0; i < 10; i++ {
+			for k := i; k < len(jobs); k += 10 {
+				if pop := h.pop(); pop != jobs[k] {
+					t.Fatalf("pop seq = %v expected %v", pop.seq, jobs[k].seq)
+				}
+			}
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		h := newPriorityHeap(0)
+		jobs := []*job{
+			{t: times[1], priority: 0, seq: 1},
+			{t: times[2], priority: 5, seq: 2},
+			{t: times[0], priority: 1, seq: 3},
+			{t: times[1], priority: 5, seq: 4},
+			{t: times[1], priority: 5, seq: 5},
+		}
+		for _, j := range jobs {
+			h.add(j)
+		}
+		if latest := h.latest(); latest != jobs[1] {
+			t.Errorf("latest = %v expected %v", latest.t, jobs[1].t)
+		}
+		for _, i := range []int{3, 4, 1, 2, 0} {
+			if pop := h.pop(); pop != jobs[i] {
+				t.Errorf("pop = (%v, %v) expected (%v, %v)", pop.priority, pop.t, jobs[i].priority, jobs[i].t)
+			}
+		}
+	})
+
+	t.Run("add many", func(t *testing.T) {
+		h := newMinHeap(0)
+		h.add(&job{t: times[5]})
+		h.add(&job{t: times[1]})
+		// rebuilt with heap.Init
+		many := make([]*job, 5)
+		for i := range many {
+			many[i] = &job{t: times[9-i]}
+		}
+		h.addMany(many)
+		// pushed one by one
+		h.addMany([]*job{{t: times[0]}, {t: times[6]}})
+
+		for _, i := range []int{0, 1, 5, 5, 6, 6, 7, 8, 9} {
+			if pop := h.pop(); !pop.t.Equal(times[i]) {
+				t.Errorf("pop = %v expected %v", pop.t, times[i])
+			}
+		}
+		if h.size() != 0 {
+			t.Errorf("expect empty but size = %v", h.size())
+		}
+
+		limited := newMinHeap(3)
+		if err := limited.addMany(many); err != ErrMax {
+			t.Errorf("addMany over max expected error: %v, but %v", ErrMax, err)
+		}
+	})
 }
@@ -0,0 +1,41 @@
+package htask
+
+import (
+	"errors"
+	"time"
+)
+
+// errors
+var (
+	ErrMisfired = errors.New("task misfired")
+)
+
+// DefaultMisfireThreshold is used when TaskOption.MisfireThreshold is 0.
+const DefaultMisfireThreshold = time.Second
+
+// MisfirePolicy decides what happens to a task which starts later than MisfireThreshold after its time.
+// it happens when workers are busy or process is paused or suspended.
+type MisfirePolicy int
+
+// misfire policies
+const (
+	// MisfireRun runs late task immediately.
+	MisfireRun MisfirePolicy = iota
+	// MisfireSkip skips late task. its Handle is cancelled and Handle.Err returns ErrMisfired.
+	MisfireSkip
+	// MisfireCoalesce runs late recurring task only once for all firings missed.
+	// it is the same as MisfireRun for a task of Scheduler which fires once.
+	MisfireCoalesce
+)
+
+// misfired returns true if the job started at now should be skipped.
+func (j job) misfired(now time.Time) bool {
+	if j.misfire != MisfireSkip {
+		return false
+	}
+	threshold := j.misfireThreshold
+	if threshold <= 0 {
+		threshold = DefaultMisfireThreshold
+	}
+	return now.Sub(j.t) > threshold
+}
@@ -0,0 +1,60 @@
+package htask
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Misfire(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chInfo := make(chan TaskInfo, 3)
+	task := func(ctx context.Context, info TaskInfo) error {
+		chInfo <- info
+		return nil
+	}
+	at := clock.Now().Add(time.Second)
+	skipped, _ := scheduler.SetContextWithOption(nil, at, task, TaskOption{Misfire: MisfireSkip, MisfireThreshold: time.Minute})
+	tolerated, _ := scheduler.SetContextWithOption(nil, at, task, TaskOption{Misfire: MisfireSkip, MisfireThreshold: time.Hour})
+	late, _ := scheduler.SetContext(nil, at, task)
+
+	// suspended for 2 minutes
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 2; i++ {
+		select {
+		case info := <-chInfo:
+			if info.ID == skipped.ID() {
+				t.Errorf("misfired task executed")
+			} else if info.Late != 2*time.Minute-time.Second {
+				t.Errorf("Late = %v expected %v", info.Late, 2*time.Minute-time.Second)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("late task not executed")
+		}
+	}
+	waitStatus := func(h *Handle, expected TaskState) {
+		for i := 0; i < 100 && h.Status() != expected; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		if s := h.Status(); s != expected {
+			t.Errorf("task %v is %v expected %v", h.ID(), s, expected)
+		}
+	}
+	waitStatus(skipped, StateCancelled)
+	waitStatus(tolerated, StateDone)
+	waitStatus(late, StateDone)
+	if err := skipped.Err(); err != ErrMisfired {
+		t.Errorf("Err = %v expected %v", err, ErrMisfired)
+	}
+	if stats, _ := scheduler.Stats(); stats.Misfired != 1 || stats.Executed != 2 {
+		t.Errorf("Misfired = %v, Executed = %v expected 1, 2", stats.Misfired, stats.Executed)
+	}
+}
@@ -0,0 +1,219 @@
+package htask
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// errors
+var (
+	ErrUnknownTask = errors.New("task is not registered")
+)
+
+// NamedTask is pending task set by SetNamed. it is serializable to JSON.
+type NamedTask struct {
+	ID      uint64     `json:"id"`
+	Name    string     `json:"name"`
+	Payload []byte     `json:"payload,omitempty"`
+	Time    time.Time  `json:"time"`
+	Attempt int        `json:"attempt,omitempty"`
+	Option  TaskOption `json:"option"`
+}
+
+// TaskHandler executes task registered by name with payload given to SetNamed.
+type TaskHandler func(ctx context.Context, payload []byte, info TaskInfo) error
+
+// Register registers handler of tasks set by SetNamed with name.
+// handler of the same name is replaced.
+func (c *Scheduler) Register(name string, handler TaskHandler) error {
+	if name == "" || handler == nil {
+		return ErrInvalidTask
+	}
+	c.mu.Lock()
+	c.handlers[name] = handler
+	c.mu.Unlock()
+	return nil
+}
+
+// Registered returns sorted names of registered tasks.
+func (c *Scheduler) Registered() []string {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.handlers))
+	for name := range c.handlers {
+		names = append(names, name)
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (c *Scheduler) handler(name string) TaskHandler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.handlers[name]
+}
+
+// SetNamed enqueue new task which runs handler registered by name with payload.
+// the task is persisted if Option.Store is set. if name is not registered then returns ErrUnknownTask.
+func (c *Scheduler) SetNamed(name string, payload []byte, t time.Time) (*Handle, error) {
+	return c.SetNamedWithOption(name, payload, t, TaskOption{})
+}
+
+// SetNamedWithOption is SetNamed configured by option.
+func (c *Scheduler) SetNamedWithOption(name string, payload []byte, t time.Time, option TaskOption) (*Handle, error) {
+	if c.handler(name) == nil {
+		return nil, ErrUnknownTask
+	} else if _, err := c.queue(option.Queue); err != nil {
+		return nil, err
+	}
+	j := c.namedJob(c.newID(), name, payload, t, option)
+	if err := c.set(j); err != nil {
+		return nil, err
+	}
+	return j.h, nil
+}
+
+// namedJob creates job which looks up handler by name when it is executed.
+// the job is executed by default workers if option.Queue is not defined.
+func (c *Scheduler) namedJob(id uint64, name string, payload []byte, t time.Time, option TaskOption) *job {
+	j := &job{
+		t:       t,
+		ctx:     context.Background(),
+		name:    name,
+		payload: payload,
+		h:       &Handle{s: c, id: id, t: t},
+	}
+	j.apply(option)
+	j.q, _ = c.queue(option.Queue)
+	j.ctxTask = func(ctx context.Context, info TaskInfo) error {
+		handler := c.handler(name)
+		if handler == nil {
+			return ErrUnknownTask
+		}
+		return handler(ctx, payload, info)
+	}
+	return j
+}
+
+// option returns TaskOption which the job is set with.
+func (j job) option() TaskOption {
+	option := TaskOption{Timeout: j.timeout, Priority: j.priority, Misfire: j.misfire, MisfireThreshold: j.misfireThreshold}
+	if j.retry != nil {
+		option.Retry = *j.retry
+	}
+	if j.q != nil {
+		option.Queue = j.q.name
+	}
+	return option
+}
+
+func (j job) named() NamedTask {
+	return NamedTask{
+		ID:      j.h.id,
+		Name:    j.name,
+		Payload: j.payload,
+		Time:    j.t,
+		Attempt: j.attempt,
+		Option:  j.option(),
+	}
+}
+
+// save writes the job to Store if the job is registered task.
+func (j job) save() error {
+	if j.name == "" || j.c.store == nil {
+		return nil
+	}
+	return j.c.store.set(j.named())
+}
+
+// saveNew writes the job to Store. returns ErrDuplicateID if Store has a task of the same ID.
+func (j job) saveNew() error {
+	if j.name == "" || j.c.store == nil {
+		return nil
+	}
+	return j.c.store.add(j.named())
+}
+
+// saveAsync writes the job to Store without waiting. chErr must be buffered and receives the result once.
+func (j job) saveAsync(chErr chan<- error) {
+	if j.name == "" || j.c.store == nil {
+		chErr <- nil
+		return
+	}
+	j.c.store.setAsync(j.named(), chErr)
+}
+
+// forget removes the job from Store because it never runs again.
+func (j job) forget() {
+	if j.name == "" || j.c == nil || j.c.store == nil {
+		return
+	}
+	j.c.store.remove(j.h.id)
+}
+
+// restore creates jobs from records in Store.
+func (c *Scheduler) restore() []*job {
+	records := c.store.pending()
+	jobs := make([]*job, len(records))
+	for i, r := range records {
+		j := c.namedJob(r.ID, r.Name, r.Payload, r.Time, r.Option)
+		j.c = c
+		j.attempt = r.Attempt
+		jobs[i] = j
+		c.useID(r.ID)
+	}
+	return jobs
+}
+
+// named returns pending named tasks ordered by time.
+func (s *scheduleState) named() []NamedTask {
+	var tasks []NamedTask
+	f := func(j *job) {
+		if j.name != "" {
+			tasks = append(tasks, j.named())
+		}
+	}
+	s.due.each(f)
+	for _, q := range s.queues {
+		q.due.each(f)
+	}
+	s.queue.each(f)
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].Time.Equal(tasks[j].Time) {
+			return tasks[i].Time.Before(tasks[j].Time)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// List returns pending tasks set by SetNamed ordered by time. tasks already dispatched are not included.
+// if Scheduler is already closed then returns ErrClosed.
+func (c *Scheduler) List() ([]NamedTask, error) {
+	chList := make(chan []NamedTask, 1)
+	select {
+	case <-c.chClose:
+		return nil, ErrClosed
+	case c.chList <- chList:
+	}
+	return <-chList, nil
+}
+
+// Dump writes pending tasks set by SetNamed to w as JSON per line.
+func (c *Scheduler) Dump(w io.Writer) error {
+	tasks, err := c.List()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, task := range tasks {
+		if err := enc.Encode(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
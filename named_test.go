@@ -0,0 +1,70 @@
+package htask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Register(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	noop := func(ctx context.Context, payload []byte, info TaskInfo) error { return nil }
+	if err := scheduler.Register("", noop); err != ErrInvalidTask {
+		t.Errorf("Register empty name : %v expected %v", err, ErrInvalidTask)
+	}
+	if err := scheduler.Register("noop", nil); err != ErrInvalidTask {
+		t.Errorf("Register nil handler : %v expected %v", err, ErrInvalidTask)
+	}
+	scheduler.Register("send-email", noop)
+	scheduler.Register("noop", noop)
+	if names := scheduler.Registered(); !reflect.DeepEqual(names, []string{"noop", "send-email"}) {
+		t.Errorf("Registered = %v", names)
+	}
+
+	later := time.Now().Add(time.Hour)
+	h1, _ := scheduler.SetNamed("send-email", []byte("to:a"), later.Add(time.Minute))
+	h2, _ := scheduler.SetNamedWithOption("noop", nil, later, TaskOption{Priority: 2, Retry: RetryPolicy{MaxAttempts: 3}})
+	scheduler.Set(nil, later, func(_ time.Time) {})
+	h3, _ := scheduler.SetNamed("noop", nil, later.Add(time.Second))
+	h3.Cancel()
+
+	expected := []NamedTask{
+		{ID: h2.ID(), Name: "noop", Time: later, Option: TaskOption{Priority: 2, Retry: RetryPolicy{MaxAttempts: 3}}},
+		{ID: h1.ID(), Name: "send-email", Payload: []byte("to:a"), Time: later.Add(time.Minute)},
+	}
+	tasks, err := scheduler.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("List = %+v expected %+v", tasks, expected)
+	}
+
+	var buf bytes.Buffer
+	if err := scheduler.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	for i := range expected {
+		var task NamedTask
+		if err := dec.Decode(&task); err != nil {
+			t.Fatal(err)
+		}
+		if task.ID != expected[i].ID || task.Name != expected[i].Name || !bytes.Equal(task.Payload, expected[i].Payload) || !task.Time.Equal(expected[i].Time) {
+			t.Errorf("dumped %+v expected %+v", task, expected[i])
+		}
+	}
+	if dec.More() {
+		t.Errorf("too many tasks are dumped")
+	}
+}
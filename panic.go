@@ -0,0 +1,78 @@
+package htask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// errors
+var (
+	ErrTaskPanicked = errors.New("task panicked")
+)
+
+// PanicError is error of task which panicked and recovered by Option.PanicHandler.
+// it unwraps to ErrTaskPanicked.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+// Unwrap returns ErrTaskPanicked.
+func (e *PanicError) Unwrap() error {
+	return ErrTaskPanicked
+}
+
+// PanicInfo is information of panic recovered from task.
+type PanicInfo struct {
+	// ID is Handle.ID of the task. 0 if task is set by Set.
+	ID uint64
+	// Time is the time passed to task.
+	Time  time.Time
+	Value interface{}
+	Stack []byte
+}
+
+// PanicHandler handles panic recovered from task.
+// it is called in the goroutine which ran the task.
+type PanicHandler func(p PanicInfo)
+
+// LogPanic logs recovered panic by standard logger and keeps scheduler running.
+func LogPanic(p PanicInfo) {
+	log.Printf("htask: task (id=%v, time=%v) panicked: %v\n%s", p.ID, p.Time, p.Value, p.Stack)
+}
+
+// RePanic panics again with recovered value. process will crash as if task is not recovered.
+func RePanic(p PanicInfo) {
+	panic(p.Value)
+}
+
+// exec executes task. if Option.PanicHandler is set, panic in task is recovered
+// and returned as *PanicError, then the task fails and may be retried.
+func (c *Scheduler) exec(ctx context.Context, j job) (err error) {
+	if c.onPanic != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r}
+				atomic.AddUint64(&c.countersOf(&j).panicked, 1)
+				p := PanicInfo{Time: j.fired, Value: r, Stack: debug.Stack()}
+				if j.h != nil {
+					p.ID = j.h.id
+				}
+				c.onPanic(p)
+			}
+		}()
+	}
+	if j.ctxTask != nil {
+		return j.ctxTask(ctx, j.info())
+	}
+	j.task(j.fired)
+	return nil
+}
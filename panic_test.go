@@ -0,0 +1,93 @@
+package htask
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_PanicHandler(t *testing.T) {
+	for _, workers := range []int{0, 1} {
+		var wg sync.WaitGroup
+		chPanic := make(chan PanicInfo, 1)
+		chDead := make(chan error, 1)
+		scheduler := NewSchedulerWithOption(&wg, Option{
+			Workers:           workers,
+			PanicHandler:      func(p PanicInfo) { chPanic <- p },
+			DeadLetterHandler: func(info TaskInfo, err error) { chDead <- err },
+		})
+
+		now := time.Now()
+		h, _ := scheduler.SetTask(now, func(_ time.Time) { panic("boom") })
+		select {
+		case p := <-chPanic:
+			if p.Value != "boom" || p.ID != h.ID() || len(p.Stack) == 0 {
+				t.Errorf("workers(%v) panic info = %+v", workers, p)
+			}
+		case <-time.After(time.Millisecond * 100):
+			t.Fatalf("workers(%v) panic handler not called", workers)
+		}
+
+		// scheduler and workers keep working
+		chResult := make(chan struct{})
+		scheduler.Set(nil, time.Now(), func(_ time.Time) { close(chResult) })
+		select {
+		case <-chResult:
+		case <-time.After(time.Millisecond * 100):
+			t.Fatalf("workers(%v) task not executed after panic", workers)
+		}
+
+		var stats Stats
+		for i := 0; i < 100; i++ {
+			if stats, _ = scheduler.Stats(); stats.Executed == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if stats.Panicked != 1 || stats.Failed != 1 || stats.Executed != 2 || stats.Busy != 0 {
+			t.Errorf("workers(%v) stats = %+v", workers, stats)
+		}
+		if s := h.Status(); s != StateFailed {
+			t.Errorf("workers(%v) status = %v expected %v", workers, s, StateFailed)
+		}
+		if err, ok := h.Err().(*PanicError); !ok || err.Value != "boom" || !errors.Is(err, ErrTaskPanicked) {
+			t.Errorf("workers(%v) error = %v expected %v", workers, h.Err(), ErrTaskPanicked)
+		}
+		if err := <-chDead; err != h.Err() {
+			t.Errorf("workers(%v) dead letter error = %v expected %v", workers, err, h.Err())
+		}
+		scheduler.Close()
+		wg.Wait()
+	}
+}
+
+func TestScheduler_PanicRetry(t *testing.T) {
+	var wg sync.WaitGroup
+	chDead := make(chan TaskInfo, 1)
+	scheduler := NewSchedulerWithOption(&wg, Option{
+		Workers:           1,
+		PanicHandler:      func(p PanicInfo) {},
+		DeadLetterHandler: func(info TaskInfo, err error) { chDead <- info },
+	})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	h, _ := scheduler.SetContextWithOption(nil, time.Now(), func(ctx context.Context, info TaskInfo) error {
+		panic("boom")
+	}, TaskOption{Retry: RetryPolicy{MaxAttempts: 3}})
+	select {
+	case info := <-chDead:
+		if info.Attempt != 3 {
+			t.Errorf("attempt = %v expected 3", info.Attempt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panicked task is not passed to dead letter handler")
+	}
+	if s := h.Status(); s != StateFailed {
+		t.Errorf("status = %v expected %v", s, StateFailed)
+	}
+}
@@ -0,0 +1,22 @@
+package htask
+
+// Pause stops dispatching tasks until Resume is called. Set and other operations are still accepted.
+// running tasks are not stopped. if Scheduler is already closed then returns ErrClosed.
+func (c *Scheduler) Pause() error {
+	return c.pause(true)
+}
+
+// Resume restarts dispatching tasks. tasks which became due while paused are dispatched at once
+// and TaskOption.Misfire of them is applied. if Scheduler is already closed then returns ErrClosed.
+func (c *Scheduler) Resume() error {
+	return c.pause(false)
+}
+
+func (c *Scheduler) pause(paused bool) error {
+	select {
+	case <-c.chClose:
+		return ErrClosed
+	case c.chPause <- paused:
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package htask
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Pause(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chInfo := make(chan TaskInfo, 2)
+	task := func(ctx context.Context, info TaskInfo) error {
+		chInfo <- info
+		return nil
+	}
+	if err := scheduler.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	at := clock.Now().Add(time.Second)
+	late, err := scheduler.SetContext(nil, at, task)
+	if err != nil {
+		t.Fatalf("SetContext while paused : %v", err)
+	}
+	skipped, _ := scheduler.SetContextWithOption(nil, at, task, TaskOption{Misfire: MisfireSkip, MisfireThreshold: time.Minute})
+	clock.Advance(2 * time.Minute)
+	select {
+	case info := <-chInfo:
+		t.Fatalf("task %v executed while paused", info.ID)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if stats, _ := scheduler.Stats(); !stats.Paused || stats.Pending != 2 {
+		t.Errorf("Paused = %v, Pending = %v expected true, 2", stats.Paused, stats.Pending)
+	}
+
+	if err := scheduler.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case info := <-chInfo:
+		if info.ID != late.ID() || info.Late != 2*time.Minute-time.Second {
+			t.Errorf("executed %v late %v expected %v late %v", info.ID, info.Late, late.ID(), 2*time.Minute-time.Second)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task not executed after Resume")
+	}
+	for i := 0; i < 100 && skipped.Status() == StatePending; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if err := skipped.Err(); err != ErrMisfired {
+		t.Errorf("Err = %v expected %v", err, ErrMisfired)
+	}
+	if stats, _ := scheduler.Stats(); stats.Paused || stats.Pending != 0 {
+		t.Errorf("Paused = %v, Pending = %v expected false, 0", stats.Paused, stats.Pending)
+	}
+
+	scheduler.Close()
+	if err := scheduler.Pause(); err != ErrClosed {
+		t.Errorf("Pause closed scheduler : %v expected %v", err, ErrClosed)
+	}
+}
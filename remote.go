@@ -0,0 +1,82 @@
+package htask
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// maxRequestBody is max size of request body accepted by handler.
+const maxRequestBody = 1 << 20
+
+type httpHandler struct {
+	s *Scheduler
+}
+
+// NewHTTPHandler returns http.Handler to submit and list tasks registered by name remotely.
+// POST accepts NamedTask as JSON. ID and Attempt are ignored and zero Time means now.
+// it responds 201 Created with the submitted NamedTask which has ID.
+// request body larger than 1MB is rejected with 413 Request Entity Too Large.
+// GET responds pending tasks returned by Scheduler.List.
+func NewHTTPHandler(s *Scheduler) http.Handler {
+	return httpHandler{s: s}
+}
+
+func (h httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tasks, err := h.s.List()
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err))
+			return
+		} else if tasks == nil {
+			tasks = []NamedTask{}
+		}
+		writeJSON(w, http.StatusOK, tasks)
+	case http.MethodPost:
+		var task NamedTask
+		body := http.MaxBytesReader(w, r.Body, maxRequestBody)
+		if err := json.NewDecoder(body).Decode(&task); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if task.Time.IsZero() {
+			task.Time = h.s.clock.Now()
+		}
+		handle, err := h.s.SetNamedWithOption(task.Name, task.Payload, task.Time, task.Option)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err))
+			return
+		}
+		task.ID = handle.ID()
+		task.Attempt = 0
+		writeJSON(w, http.StatusCreated, task)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func httpStatus(err error) int {
+	switch err {
+	case ErrUnknownTask:
+		return http.StatusNotFound
+	case ErrInvalidTime, ErrInvalidTask, ErrUnknownQueue:
+		return http.StatusBadRequest
+	case ErrClosed, ErrMax:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,96 @@
+package htask
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	chPayload := make(chan string, 1)
+	scheduler.Register("echo", func(ctx context.Context, payload []byte, info TaskInfo) error {
+		chPayload <- string(payload)
+		return nil
+	})
+	server := httptest.NewServer(NewHTTPHandler(scheduler))
+	defer server.Close()
+
+	post := func(body string) (*http.Response, NamedTask) {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var task NamedTask
+		if resp.StatusCode == http.StatusCreated {
+			if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, task
+	}
+
+	// "aGVsbG8=" is "hello" in base64
+	resp, task := post(`{"name":"echo","payload":"aGVsbG8="}`)
+	if resp.StatusCode != http.StatusCreated || task.ID == 0 || task.Time.IsZero() {
+		t.Errorf("POST : %v, %+v", resp.Status, task)
+	}
+	select {
+	case p := <-chPayload:
+		if p != "hello" {
+			t.Errorf("payload = %v expected hello", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("submitted task not executed")
+	}
+
+	later := time.Now().Add(time.Hour).UTC()
+	resp, task = post(`{"name":"echo","time":"` + later.Format(time.RFC3339Nano) + `","option":{"Priority":1}}`)
+	if resp.StatusCode != http.StatusCreated || !task.Time.Equal(later) || task.Option.Priority != 1 {
+		t.Errorf("POST : %v, %+v", resp.Status, task)
+	}
+	if resp, _ := post(`{"name":"unknown"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("POST unknown task : %v", resp.Status)
+	}
+	if resp, _ := post(`{`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST broken json : %v", resp.Status)
+	}
+	large := `{"name":"echo","payload":"` + strings.Repeat("A", maxRequestBody) + `"}`
+	if resp, _ := post(large); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST too large body : %v", resp.Status)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tasks []NamedTask
+	err = json.NewDecoder(resp.Body).Decode(&tasks)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != task.ID {
+		t.Errorf("GET = %+v expected only %v", tasks, task.ID)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL, nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE : %v", resp.Status)
+	}
+
+	scheduler.Close()
+	wg.Wait()
+	if resp, _ := post(`{"name":"echo"}`); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("POST to closed scheduler : %v", resp.Status)
+	}
+}
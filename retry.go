@@ -0,0 +1,92 @@
+package htask
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy decides when failed task is retried. zero value means no retry.
+// MaxAttempts is max number of executions including the first one.
+// n-th retry waits BaseDelay * Multiplier^(n-1) capped by MaxDelay.
+// Multiplier 0 means 2, MaxDelay 0 means no limit.
+// Jitter randomizes each delay within ±Jitter ratio (0 ~ 1). delay with jitter is still capped by MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	Multiplier  float64
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// delay returns duration to wait before retry after attempt-th execution.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	d := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+		// jitter never exceeds MaxDelay
+		if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+			d = float64(p.MaxDelay)
+		}
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// fail retries failed job if RetryPolicy allows, or reports it to Option.DeadLetterHandler.
+func (c *Scheduler) fail(j job, err error) {
+	attempt := j.attempt + 1
+	if j.retry != nil && attempt < j.retry.MaxAttempts {
+		next := j
+		next.t = c.clock.Now().Add(j.retry.delay(attempt))
+		next.attempt = attempt
+		next.fired = time.Time{}
+		next.started = time.Time{}
+		if j.h == nil || j.h.retry(next.t, err) {
+			if c.requeue(&next) == nil {
+				atomic.AddUint64(&c.countersOf(&j).retried, 1)
+				return
+			}
+		}
+	}
+	if j.h != nil {
+		j.h.finish(err)
+	}
+	if j.name == "" || c.handler(j.name) != nil {
+		j.forget()
+	}
+	// otherwise the task is kept in Store to be restored with its handler
+	if c.onDead != nil {
+		c.onDead(j.info(), err)
+	}
+}
+
+// requeue enqueues retried job to heap. it bypasses Option.Overflow because the task is already accepted,
+// so that worker is not blocked by full heap.
+func (c *Scheduler) requeue(j *job) error {
+	select {
+	case <-j.chCancel:
+		return ErrTaskCancelled
+	default:
+	}
+	if err := j.save(); err != nil {
+		return err
+	}
+	select {
+	case <-c.chClose:
+		j.forget()
+		return ErrClosed
+	case c.chRetry <- j:
+		return nil
+	}
+}
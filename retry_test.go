@@ -0,0 +1,158 @@
+package htask
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		if d := p.delay(i + 1); d != expected {
+			t.Errorf("delay(%v) = %v expected %v", i+1, d, expected)
+		}
+	}
+
+	p = RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, Multiplier: 3, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := p.delay(3); d < 4500*time.Millisecond || d > 13500*time.Millisecond {
+			t.Errorf("delay with jitter = %v", d)
+		}
+	}
+
+	p = RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := p.delay(5); d < 2500*time.Millisecond || d > 5*time.Second {
+			t.Errorf("delay with jitter and MaxDelay = %v", d)
+		}
+	}
+}
+
+func TestScheduler_Retry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	errTask := errors.New("task error")
+	chDead := make(chan TaskInfo, 1)
+	scheduler := NewSchedulerWithOption(&wg, Option{
+		Workers: 1,
+		Clock:   clock,
+		DeadLetterHandler: func(info TaskInfo, err error) {
+			if err != errTask {
+				t.Errorf("dead letter error = %v expected %v", err, errTask)
+			}
+			chDead <- info
+		},
+	})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chAttempt := make(chan TaskInfo)
+	task := func(ctx context.Context, info TaskInfo) error {
+		chAttempt <- info
+		if info.Attempt == 2 {
+			return nil
+		}
+		return errTask
+	}
+	retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute}
+
+	// succeeds at second attempt
+	h, _ := scheduler.SetContextWithOption(context.Background(), clock.Now(), task, TaskOption{Retry: retry})
+	if info := <-chAttempt; info.Attempt != 1 {
+		t.Errorf("attempt = %v expected 1", info.Attempt)
+	}
+	for i := 0; i < 100 && h.Status() != StatePending; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if s, err := h.Status(), h.Err(); s != StatePending || err != errTask || !h.Time().Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("retrying handle = %v, %v, %v", s, err, h.Time())
+	}
+	clock.Advance(time.Minute)
+	if info := <-chAttempt; info.Attempt != 2 {
+		t.Errorf("attempt = %v expected 2", info.Attempt)
+	}
+	for i := 0; i < 100 && h.Status() != StateDone; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if s := h.Status(); s != StateDone {
+		t.Errorf("status = %v expected %v", s, StateDone)
+	}
+
+	// always fails
+	h, _ = scheduler.SetContextWithOption(context.Background(), clock.Now(), func(ctx context.Context, info TaskInfo) error {
+		chAttempt <- info
+		return errTask
+	}, TaskOption{Retry: retry})
+	<-chAttempt
+	for i := 0; i < 100 && h.Status() != StatePending; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	<-chAttempt
+	for i := 0; i < 100 && !h.Time().Equal(clock.Now().Add(2*time.Minute)); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(2 * time.Minute)
+	if info := <-chAttempt; info.Attempt != 3 {
+		t.Errorf("attempt = %v expected 3", info.Attempt)
+	}
+	select {
+	case info := <-chDead:
+		if info.ID != h.ID() || info.Attempt != 3 {
+			t.Errorf("dead letter info = %+v", info)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("dead letter handler not called")
+	}
+	if s := h.Status(); s != StateFailed {
+		t.Errorf("status = %v expected %v", s, StateFailed)
+	}
+
+	stats, _ := scheduler.Stats()
+	if stats.Retried != 3 || stats.Failed != 4 || stats.Executed != 5 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestScheduler_RetryFullHeap(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, MaxPending: 1, Overflow: OverflowBlock})
+
+	chStarted, chFail := make(chan struct{}), make(chan struct{})
+	task := func(ctx context.Context, info TaskInfo) error {
+		close(chStarted)
+		<-chFail
+		return errors.New("task error")
+	}
+	later := time.Now().Add(time.Hour)
+	scheduler.SetContextWithOption(nil, time.Now(), task, TaskOption{Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour}})
+	<-chStarted
+	// heap is full while the task is running
+	scheduler.Set(nil, later, func(_ time.Time) {})
+	close(chFail)
+
+	for i := 0; i < 100 && scheduler.Len() != 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := scheduler.Len(); n != 2 {
+		t.Errorf("Len = %v expected 2", n)
+	}
+	chClosed := make(chan error)
+	go func() {
+		chClosed <- scheduler.Close()
+	}()
+	select {
+	case err := <-chClosed:
+		if err != nil {
+			t.Errorf("Close error : %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close is blocked by retried task")
+	}
+	wg.Wait()
+}
@@ -1,8 +1,10 @@
 package htask
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,184 +15,806 @@ var (
 	ErrInvalidTime    = errors.New("time is invalid zero time")
 	ErrInvalidTask    = errors.New("task must not be nil")
 	ErrTaskCancelled  = errors.New("task cancelled")
+	ErrTaskStarted    = errors.New("task is already started")
+	ErrTaskTimeout    = errors.New("task timed out")
 )
 
 type job struct {
-	chCancel <-chan struct{}
-	t        time.Time
-	task     func(time.Time)
+	c                *Scheduler // scheduler which the job is set to.
+	chCancel         <-chan struct{}
+	t                time.Time
+	task             func(time.Time)
+	ctx              context.Context
+	ctxTask          func(context.Context, TaskInfo) error
+	timeout          time.Duration
+	retry            *RetryPolicy
+	attempt          int // number of executions before this
+	priority         int // higher priority job is dispatched first among due jobs.
+	misfire          MisfirePolicy
+	misfireThreshold time.Duration
+	name             string // name of registered task. empty if task is closure.
+	payload          []byte
+	q                *workQueue // named queue of the job. nil means default queue.
+	h                *Handle
+	index            int          // index in heap or bucket. -1 means job is not in heap.
+	bucket           *wheelBucket // slot of timingWheel which has the job.
+	seq              uint64       // insertion order in heap.
+	fired            time.Time    // the time job became due.
+	started          time.Time    // the time worker started the job.
+}
+
+func (c *Scheduler) run(j job) {
+	counters := c.countersOf(&j)
+	select {
+	case <-j.chCancel:
+		atomic.AddUint64(&counters.cancelled, 1)
+		j.forget()
+		return
+	default:
+	}
+	now := c.clock.Now()
+	if j.misfired(now) {
+		if j.h == nil || j.h.skip(ErrMisfired) {
+			atomic.AddUint64(&counters.misfired, 1)
+		} else {
+			atomic.AddUint64(&counters.cancelled, 1)
+		}
+		j.forget()
+		return
+	}
+	j.started = now
+	ctx, cancel := c.runContext(j)
+	defer cancel()
+	if j.h != nil && !j.h.start(cancel) {
+		// cancelled
+		atomic.AddUint64(&counters.cancelled, 1)
+		j.forget()
+		return
+	}
+	atomic.AddInt64(&counters.busy, 1)
+	var err error
+	defer func() {
+		atomic.AddInt64(&counters.busy, -1)
+		atomic.AddUint64(&counters.executed, 1)
+		if err != nil {
+			atomic.AddUint64(&counters.failed, 1)
+			c.fail(j, err)
+		} else if j.h != nil {
+			j.h.finish(nil)
+			j.forget()
+		}
+	}()
+	err = c.execTimeout(ctx, cancel, j)
+}
+
+// discard is called when job is removed from heap without executed.
+func (j job) discard() {
+	if j.h != nil {
+		j.h.discard()
+	}
+}
+
+// OverflowPolicy decides how Set behaves when pending jobs reach Option.MaxPending.
+type OverflowPolicy int
+
+// overflow policies
+const (
+	// OverflowBlock blocks Set until a pending job is dispatched.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowError makes Set return ErrMax.
+	OverflowError
+	// OverflowDropNewest discards the job passed to Set without error.
+	OverflowDropNewest
+	// OverflowEvictLatest discards the pending job which has the furthest time.
+	// if the new job is the furthest, the new job is discarded.
+	OverflowEvictLatest
+)
+
+// Option can accept zero value.
+// Workers is number of worker goroutine.
+// MaxConcurrency limits number of goroutines running tasks when Workers is 0. 0 means no limit.
+// excess due tasks wait until running tasks finish.
+// MaxPending limits the number of pending jobs in heap. 0 means no limit.
+// Overflow is applied when the number of pending jobs reaches MaxPending.
+// PanicHandler is called when task panics. if nil, panic is not recovered and crashes process.
+// Clock is used to get current time and timer. if nil, RealClock is used.
+// TaskTimeout is default execution timeout of tasks. 0 means no timeout.
+// TimeoutHandler is called when task exceeds its timeout.
+// DeadLetterHandler is called when task fails and will not be retried any more.
+// Store persists tasks set by SetNamed. pending tasks in Store are restored when Scheduler is created.
+// Handlers registers handlers of named tasks before restored tasks start. invalid entries are ignored.
+// WheelTick makes pending tasks held by hierarchical timing wheel instead of min heap.
+// tasks are executed at the first tick after their time. 0 means min heap is used.
+// Autoscale changes Workers automatically while Scheduler is running.
+// Queues defines named queues and their number of workers. tasks are set to them by TaskOption.Queue.
+// PanicHandler, TimeoutHandler and DeadLetterHandler are called in the goroutine which ran the task.
+type Option struct {
+	Workers           int
+	MaxConcurrency    int
+	MaxPending        int
+	Overflow          OverflowPolicy
+	PanicHandler      PanicHandler
+	Clock             Clock
+	TaskTimeout       time.Duration
+	TimeoutHandler    func(info TaskInfo)
+	DeadLetterHandler func(info TaskInfo, err error)
+	WheelTick         time.Duration
+	Store             *Store
+	Handlers          map[string]TaskHandler
+	Autoscale         AutoscalePolicy
+	Queues            map[string]int
 }
 
 // Scheduler is used to schedule tasks.
 type Scheduler struct {
-	chClose   chan struct{}
-	wg        *sync.WaitGroup
-	chJob     chan job
+	lastID   *uint64 // accessed atomically. shared by shards of ShardedScheduler.
+	counters counters
+	chClose  chan struct{}
+	wg       *sync.WaitGroup
+	chJob    chan *job
+	// chRetry receives retried job. it is never blocked by Option.Overflow.
+	chRetry      chan *job
+	chBatch      chan batch
+	chFull       chan struct{}
+	chRemove     chan *Handle
+	chReschedule chan reschedule
+	// chCancelled receives cancel channel which is closed.
+	chCancelled chan (<-chan struct{})
+	chStats     chan chan<- Stats
+	chList      chan chan<- []NamedTask
+	chPause     chan bool
+	// named queues. queues is not changed after created.
+	queues         map[string]*workQueue
+	chPull         chan *workQueue
+	chQueueWorkers chan queueWorkers
+	chQueueStats   chan queueStats
+	chShutdown     chan shutdown
+	// chAbort is closed when running tasks should stop. it cancels context of tasks.
+	chAbort   chan struct{}
+	abortOnce sync.Once
 	chWork    chan job
+	// sem limits goroutines created for each task. nil means no limit.
+	sem       chan struct{}
 	chFin     chan struct{}
 	chWorkers chan int
+	// wmu serializes changes of workers size. wNum is protected by wmu.
+	wmu       sync.Mutex
 	wNum      int
+	closed    int32 // accessed atomically. 1 if chClose is closed.
+	onPanic   PanicHandler
+	clock     Clock
+	timeout   time.Duration
+	onTimeout func(info TaskInfo)
+	onDead    func(info TaskInfo, err error)
+	store     *Store
+	// handlers is registered tasks. protected by mu.
+	handlers map[string]TaskHandler
+	mu       sync.RWMutex
+	// running counts workers and goroutines created for each task.
+	running sync.WaitGroup
 }
 
 // NewScheduler creates Scheduler and start scheduler and workers.
 // number of created goroutines is counted to sync.WaitGroup.
 func NewScheduler(wg *sync.WaitGroup, workers int) *Scheduler {
-	if workers < 0 {
-		workers = 0
+	return NewSchedulerWithOption(wg, Option{Workers: workers})
+}
+
+// NewSchedulerWithOption creates Scheduler configured by option.
+// number of created goroutines is counted to sync.WaitGroup.
+func NewSchedulerWithOption(wg *sync.WaitGroup, option Option) *Scheduler {
+	if option.Workers < 0 {
+		option.Workers = 0
 	}
-	c := &Scheduler{
-		chClose:   make(chan struct{}),
-		wg:        wg,
-		chJob:     make(chan job),
-		chWork:    make(chan job),
-		chFin:     make(chan struct{}),
-		chWorkers: make(chan int),
-	}
-	for i := 0; i < workers; i++ {
+	autoscale := option.Autoscale.Max > 0
+	if autoscale {
+		option.Autoscale = option.Autoscale.withDefault()
+		option.Workers = option.Autoscale.clamp(option.Workers)
+	}
+	c := newScheduler(wg, option, make(chan job), newSemaphore(option.MaxConcurrency), new(uint64))
+	for i := 0; i < option.Workers; i++ {
 		wg.Add(1)
+		c.running.Add(1)
 		go c.worker(wg)
 		c.wNum++
 	}
+	if autoscale {
+		wg.Add(1)
+		go c.autoscale(wg, option.Autoscale)
+	}
+	return c
+}
+
+// newSemaphore creates semaphore of size n. returns nil if n <= 0.
+func newSemaphore(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+// newScheduler creates Scheduler dispatching jobs to chWork and starts scheduler goroutine without workers.
+// sem limits goroutines running tasks while there are no workers. lastID is the counter of task IDs.
+func newScheduler(wg *sync.WaitGroup, option Option, chWork chan job, sem chan struct{}, lastID *uint64) *Scheduler {
+	if option.Clock == nil {
+		option.Clock = RealClock
+	}
+	c := &Scheduler{
+		lastID:         lastID,
+		chClose:        make(chan struct{}),
+		wg:             wg,
+		chJob:          make(chan *job),
+		chRetry:        make(chan *job),
+		chBatch:        make(chan batch),
+		chFull:         make(chan struct{}),
+		chRemove:       make(chan *Handle),
+		chReschedule:   make(chan reschedule),
+		chCancelled:    make(chan (<-chan struct{})),
+		chStats:        make(chan chan<- Stats),
+		chList:         make(chan chan<- []NamedTask),
+		chPause:        make(chan bool),
+		queues:         make(map[string]*workQueue),
+		chPull:         make(chan *workQueue),
+		chQueueWorkers: make(chan queueWorkers),
+		chQueueStats:   make(chan queueStats),
+		chShutdown:     make(chan shutdown),
+		chAbort:        make(chan struct{}),
+		chWork:         chWork,
+		sem:            sem,
+		chFin:          make(chan struct{}),
+		chWorkers:      make(chan int),
+		onPanic:        option.PanicHandler,
+		clock:          option.Clock,
+		timeout:        option.TaskTimeout,
+		onTimeout:      option.TimeoutHandler,
+		onDead:         option.DeadLetterHandler,
+		store:          option.Store,
+		handlers:       make(map[string]TaskHandler),
+	}
+	for name, handler := range option.Handlers {
+		if name != "" && handler != nil {
+			c.handlers[name] = handler
+		}
+	}
+	for name := range option.Queues {
+		if name != "" {
+			c.queues[name] = newWorkQueue(name)
+		}
+	}
+	var restored []*job
+	if c.store != nil {
+		restored = c.restore()
+	}
 	wg.Add(1)
-	go c.scheduler(wg, workers)
+	go c.scheduler(wg, option, restored)
 	return c
 }
 
 // Set enqueue new task to scheduler heap queue.
 // task will be cancelled by closing chCancel. chCancel == nil is acceptable.
+// if heap is full, Set follows Option.Overflow.
 func (c *Scheduler) Set(chCancel <-chan struct{}, t time.Time, task func(time.Time)) error {
+	return c.set(&job{chCancel: chCancel, t: t, task: task})
+}
+
+// SetTask enqueue new task to scheduler heap queue and returns Handle of the task.
+// task can be cancelled or rescheduled by the Handle.
+func (c *Scheduler) SetTask(t time.Time, task func(time.Time)) (*Handle, error) {
+	h := &Handle{s: c, id: c.newID(), t: t}
+	if err := c.set(&job{t: t, task: task, h: h}); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// newID returns new ID of task.
+func (c *Scheduler) newID() uint64 {
+	return atomic.AddUint64(c.lastID, 1)
+}
+
+// useID makes IDs returned by newID bigger than id.
+func (c *Scheduler) useID(id uint64) {
+	for {
+		last := atomic.LoadUint64(c.lastID)
+		if id <= last || atomic.CompareAndSwapUint64(c.lastID, last, id) {
+			return
+		}
+	}
+}
+
+type reschedule struct {
+	h     *Handle
+	t     time.Time
+	chErr chan<- error
+}
+
+// Reschedule moves pending task referred by h to t atomically.
+// if task is already started then returns ErrTaskStarted,
+// if task is already cancelled then returns ErrTaskCancelled.
+// if the task is persisted and writing Store fails then returns the error, while the task is rescheduled.
+func (c *Scheduler) Reschedule(h *Handle, t time.Time) error {
 	if t.IsZero() {
 		return ErrInvalidTime
-	} else if task == nil {
+	} else if h == nil || h.s != c {
 		return ErrInvalidTask
 	}
+	chErr := make(chan error, 1)
 	select {
 	case <-c.chClose:
 		return ErrClosed
-	case <-chCancel:
+	case c.chReschedule <- reschedule{h: h, t: t, chErr: chErr}:
+	}
+	return <-chErr
+}
+
+func (c *Scheduler) set(j *job) error {
+	if j.t.IsZero() {
+		return ErrInvalidTime
+	} else if j.task == nil && j.ctxTask == nil {
+		return ErrInvalidTask
+	}
+	select {
+	case <-j.chCancel:
 		return ErrTaskCancelled
-	case c.chJob <- job{chCancel: chCancel, t: t, task: task}:
+	default:
+	}
+	j.c = c
+	if err := j.save(); err != nil {
+		return err
+	}
+	var err error
+	select {
+	case <-c.chClose:
+		err = ErrClosed
+	case <-j.chCancel:
+		err = ErrTaskCancelled
+	case c.chJob <- j:
 		return nil
+	case <-c.chFull:
+		err = ErrMax
 	}
+	j.forget()
+	return err
 }
 
 type scheduleState struct {
-	heap     *minHeap
-	job      job
+	queue    queue    // jobs waiting for their time
+	due      *minHeap // jobs which are due and waiting for worker
+	max      int      // max number of pending jobs. 0 means no limit.
+	seq      uint64   // sequence number of last added job
+	job      job      // copy of head of due.
+	timerAt  time.Time
 	chWork   chan<- job
-	timer    *time.Timer
+	clock    Clock
+	timer    Timer
 	expired  bool // timer is expired or not
 	lastTime time.Time
+	cancels  map[<-chan struct{}]*cancelGroup
+	watch    func(j *job) func()
+	counters *counters
 
 	chWorkPrivate chan<- job // cache
+
+	queues []*workQueue // named queues ordered by name
+}
+
+// newQueue creates timing wheel if option.WheelTick is set, otherwise min heap.
+func newQueue(option Option) queue {
+	if option.WheelTick > 0 {
+		return newTimingWheel(option.WheelTick, option.Clock.Now())
+	}
+	return newMinHeap(0)
 }
 
-func newScheduleState(heapSize int, chWork chan<- job) *scheduleState {
-	timer := time.NewTimer(time.Second)
+func newScheduleState(q queue, heapSize int, clock Clock, chWork chan<- job, watch func(j *job) func(), counters *counters) *scheduleState {
+	timer := clock.NewTimer(time.Second)
 	if !timer.Stop() {
-		<-timer.C
+		<-timer.C()
+	}
+	if heapSize < 0 {
+		heapSize = 0
 	}
 	return &scheduleState{
-		heap:          newMinHeap(heapSize),
+		queue:         q,
+		due:           newPriorityHeap(0),
+		max:           heapSize,
+		clock:         clock,
 		timer:         timer,
 		expired:       true,
+		cancels:       make(map[<-chan struct{}]*cancelGroup),
+		watch:         watch,
+		counters:      counters,
 		chWorkPrivate: chWork,
 	}
 }
 
-func (s *scheduleState) add(newJob job) error {
-	if err := s.heap.add(newJob); err != nil {
-		return err
+// size returns number of pending jobs including due jobs.
+func (s *scheduleState) size() int {
+	return s.queue.size() + s.due.size() + s.queued()
+}
+
+// queued returns number of due jobs of named queues.
+func (s *scheduleState) queued() int {
+	n := 0
+	for _, q := range s.queues {
+		n += q.due.size()
 	}
-	if !s.expired && !s.timer.Stop() {
-		<-s.timer.C
+	return n
+}
+
+// dueOf returns due jobs which the job is moved to when it is due.
+func (s *scheduleState) dueOf(j *job) *minHeap {
+	if j.q != nil {
+		return j.q.due
 	}
-	// TODO: if job is expired not reset for performance
-	s.job = s.heap.peek()
-	s.chWork = nil
-	// s.job must not be empty
-	s.timer.Reset(s.job.t.Sub(time.Now()))
-	s.expired = false
+	return s.due
+}
+
+func (s *scheduleState) full() bool {
+	return s.max > 0 && s.size() >= s.max
+}
+
+// latest returns the pending job which has the furthest time.
+func (s *scheduleState) latest() *job {
+	latest := s.queue.latest()
+	if j := s.due.latest(); latest == nil || (j != nil && latest.t.Before(j.t)) {
+		latest = j
+	}
+	for _, q := range s.queues {
+		if j := q.due.latest(); latest == nil || (j != nil && latest.t.Before(j.t)) {
+			latest = j
+		}
+	}
+	return latest
+}
+
+// push adds new job following policy if heap is full. returns the job discarded by policy.
+func (s *scheduleState) push(newJob *job, policy OverflowPolicy) *job {
+	if s.full() {
+		switch policy {
+		case OverflowEvictLatest:
+			latest := s.latest()
+			if !newJob.t.Before(latest.t) {
+				// new job is the furthest
+				newJob.discard()
+				newJob.forget()
+				atomic.AddUint64(&s.counters.dropped, 1)
+				return newJob
+			}
+			s.remove(latest)
+			atomic.AddUint64(&s.counters.dropped, 1)
+			s.add(newJob)
+			return latest
+		default:
+			// OverflowDropNewest.
+			// OverflowBlock and OverflowError never receive new job when heap is full.
+			newJob.discard()
+			newJob.forget()
+			atomic.AddUint64(&s.counters.dropped, 1)
+			return newJob
+		}
+	}
+	// heap has space for new job
+	s.add(newJob)
 	return nil
 }
 
-func (s *scheduleState) next() bool {
+// pushMany adds jobs at once and returns the number of handled jobs and jobs discarded among them.
+// with OverflowBlock, jobs are accepted until heap is full.
+// with OverflowError, 0 is returned and no job is accepted if all jobs can not be added.
+// with other policies, all jobs are handled and discarded jobs are returned.
+func (s *scheduleState) pushMany(jobs []*job, policy OverflowPolicy) batchResult {
+	if s.max > 0 && s.size()+len(jobs) > s.max {
+		switch policy {
+		case OverflowBlock:
+			jobs = jobs[:s.max-s.size()]
+		case OverflowError:
+			return batchResult{}
+		default:
+			// job of the batch may be evicted by later job of the batch
+			batch := make(map[*job]bool, len(jobs))
+			for _, j := range jobs {
+				batch[j] = true
+			}
+			var dropped []*job
+			for _, j := range jobs {
+				if d := s.push(j, policy); batch[d] {
+					dropped = append(dropped, d)
+				}
+			}
+			return batchResult{n: len(jobs), dropped: dropped}
+		}
+	}
+	s.addMany(jobs)
+	return batchResult{n: len(jobs)}
+}
+
+func (s *scheduleState) addMany(jobs []*job) {
+	for _, j := range jobs {
+		s.seq++
+		j.seq = s.seq
+		if j.h != nil {
+			j.h.j = j
+		}
+		s.watchCancel(j)
+	}
+	_ = s.queue.addMany(jobs)
+	s.resetTimer()
+}
+
+func (s *scheduleState) add(newJob *job) {
+	s.seq++
+	newJob.seq = s.seq
+	_ = s.queue.add(newJob)
+	if newJob.h != nil {
+		newJob.h.j = newJob
+	}
+	s.watchCancel(newJob)
+	s.resetTimer()
+}
+
+// remove removes pending job from heap or due jobs and discards it.
+func (s *scheduleState) remove(j *job) {
+	switch {
+	case s.queue.remove(j):
+		s.resetTimer()
+	case s.dueOf(j).remove(j):
+		s.resetWork()
+	default:
+		return
+	}
+	s.release(j)
+	j.discard()
+	j.forget()
+}
+
+// reschedule moves pending job of h to t. chErr must be buffered and receives the result once,
+// after the job is written to Store if it is persisted.
+func (s *scheduleState) reschedule(h *Handle, t time.Time, chErr chan<- error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.pendingErr(); err != nil {
+		chErr <- err
+		return
+	} else if h.j == nil {
+		// job is already dispatched to worker
+		chErr <- ErrTaskStarted
+		return
+	}
+	h.t = t
+	h.j.t = t
+	h.j.saveAsync(chErr)
+	if s.dueOf(h.j).remove(h.j) {
+		// due job waits for its new time again
+		_ = s.queue.add(h.j)
+		s.resetWork()
+	} else {
+		s.queue.fix(h.j)
+	}
+	s.resetTimer()
+}
+
+// release cleans up references to the job which left heap.
+func (s *scheduleState) release(j *job) {
+	if j.h != nil && j.h.j == j {
+		j.h.j = nil
+	}
+	s.unwatchCancel(j)
+}
+
+// resetTimer resets timer to the time queue requires if it is changed.
+func (s *scheduleState) resetTimer() {
+	next, ok := s.queue.next()
+	if !s.expired && ok && next.Equal(s.timerAt) {
+		return
+	}
 	if !s.expired && !s.timer.Stop() {
-		<-s.timer.C
+		<-s.timer.C()
 	}
-	_ = s.heap.pop()
-	s.job = s.heap.peek()
-	if s.job.t.IsZero() {
-		s.expired = true
-		s.chWork = nil
-		return false
-	} else if s.job.t.Before(s.lastTime) {
-		// skip to reset timer and execute next job directly
+	if !ok {
 		s.expired = true
-		s.chWork = s.chWorkPrivate
-		s.job.t = s.lastTime
-		return true
-	} else {
-		s.timer.Reset(s.job.t.Sub(time.Now()))
-		s.expired = false
+		return
+	}
+	s.timer.Reset(next.Sub(s.clock.Now()))
+	s.timerAt = next
+	s.expired = false
+}
+
+// resetWork copies head of due jobs to s.job and enables chWork if any job is due.
+func (s *scheduleState) resetWork() {
+	head := s.due.peek()
+	if head == nil {
+		s.job = job{}
 		s.chWork = nil
-		return false
+		return
+	}
+	s.job = *head
+	s.chWork = s.chWorkPrivate
+}
+
+// next removes the dispatched job and returns true if another job is due.
+func (s *scheduleState) next() bool {
+	if j := s.due.pop(); j != nil {
+		s.release(j)
 	}
+	s.resetWork()
+	return s.chWork != nil
 }
 
+// time is called when timer expired at t.
+// jobs which became due until now are moved together to be ordered by priority.
 func (s *scheduleState) time(t time.Time) {
 	s.expired = true
-	s.chWork = s.chWorkPrivate
-	s.job.t = t
 	s.lastTime = t
+	s.moveDue(t, s.clock.Now())
 }
 
-func (c *Scheduler) scheduler(wg *sync.WaitGroup, workers int) {
+// moveDue moves all jobs scheduled until now from heap to due jobs.
+// fired time of jobs is t, or now for jobs scheduled after t.
+func (s *scheduleState) moveDue(t, now time.Time) {
+	until := t
+	if now.After(t) {
+		until = now
+	}
+	for j := s.queue.popDue(until); j != nil; j = s.queue.popDue(until) {
+		due := s.dueOf(j)
+		if due.size() > 0 {
+			// other due jobs are still waiting for worker
+			atomic.AddUint64(&s.counters.late, 1)
+		}
+		j.fired = t
+		if j.t.After(t) {
+			j.fired = now
+		}
+		_ = due.add(j)
+	}
+	s.resetTimer()
+	s.resetWork()
+}
+
+func (c *Scheduler) scheduler(wg *sync.WaitGroup, option Option, restored []*job) {
 	defer wg.Done()
-	// no limited min heap
-	// TODO: use limited heap
-	state := newScheduleState(0, c.chWork)
+	workers := option.Workers
+	paused := false
+	state := newScheduleState(newQueue(option), option.MaxPending, option.Clock, c.chWork, c.watchCancel, &c.counters)
+	for _, name := range c.Queues() {
+		q := c.queues[name]
+		state.queues = append(state.queues, q)
+		c.changeQueueWorkers(q, option.Queues[name])
+	}
+	state.addMany(restored)
+	// named queues never send messages if they are not defined
+	chPull, chQueueWorkers, chQueueStats := c.chPull, c.chQueueWorkers, c.chQueueStats
+	if len(state.queues) == 0 {
+		chPull, chQueueWorkers, chQueueStats = nil, nil, nil
+	}
 	for {
+		chJob, chBatch, chFull := c.chJob, c.chBatch, (chan struct{})(nil)
+		// acquire semaphore for a due job waiting for running tasks to finish
+		chSpawn := (chan struct{})(nil)
+		if workers == 0 && state.chWork != nil {
+			chSpawn = c.sem
+		}
+		chTimer, chWork := state.timer.C(), state.chWork
+		if paused {
+			chTimer, chWork, chSpawn = nil, nil, nil
+		}
+		if state.full() {
+			switch option.Overflow {
+			case OverflowBlock:
+				chJob, chBatch = nil, nil
+			case OverflowError:
+				chJob, chBatch, chFull = nil, nil, c.chFull
+			}
+		}
 		select {
 		case <-c.chClose:
 			return
+		case q := <-chPull:
+			c.pull(q)
+			if !paused {
+				c.feed(state)
+			}
+		case r := <-chQueueWorkers:
+			c.changeQueueWorkers(r.q, r.workers)
+			if !paused {
+				c.feed(state)
+			}
+		case r := <-chQueueStats:
+			stats := r.q.stats(c.clock.Now())
+			stats.Paused = paused
+			r.chStats <- stats
 		case workers = <-c.chWorkers:
-			if workers == 0 && state.chWork != nil {
-				go state.job.task(state.job.t)
-				for state.next() {
-					go state.job.task(state.job.t)
-				}
+			if workers == 0 && !paused {
+				c.spawnDue(state)
+			}
+		case newJob := <-chJob:
+			state.push(newJob, option.Overflow)
+		case j := <-c.chRetry:
+			state.add(j)
+		case b := <-chBatch:
+			if b.unique && state.hasID(b.jobs) {
+				b.chResult <- batchResult{n: -1}
+			} else {
+				b.chResult <- state.pushMany(b.jobs, option.Overflow)
 			}
-		case newJob := <-c.chJob:
-			if err := state.add(newJob); err != nil {
-				// TODO: heap is unlimited then no error will occur
-				panic(err)
+		case chFull <- struct{}{}:
+			// notify Set that heap is full
+		case h := <-c.chRemove:
+			if h.j != nil {
+				atomic.AddUint64(&c.countersOf(h.j).cancelled, 1)
+				state.remove(h.j)
 			}
-		case <-state.job.chCancel:
-			for state.next() {
+		case r := <-c.chReschedule:
+			state.reschedule(r.h, r.t, r.chErr)
+		case chCancel := <-c.chCancelled:
+			state.cancel(chCancel)
+		case chStats := <-c.chStats:
+			stats := state.stats(workers)
+			stats.Paused = paused
+			chStats <- stats
+		case chList := <-c.chList:
+			chList <- state.named()
+		case p := <-c.chPause:
+			if paused && !p {
+				// dispatch all jobs which became due while paused
+				now := c.clock.Now()
+				state.moveDue(now, now)
 				if workers == 0 {
-					go state.job.task(state.job.t)
-				} else {
-					// chWork works
-					break
+					c.spawnDue(state)
 				}
+				c.feed(state)
 			}
-		case t := <-state.timer.C:
+			paused = p
+		case r := <-c.chShutdown:
+			r.chPending <- c.shutdown(state, workers, r)
+			return
+		case t := <-chTimer:
 			state.time(t)
 			if workers == 0 {
-				go state.job.task(state.job.t)
-				for state.next() {
-					go state.job.task(state.job.t)
-				}
+				c.spawnDue(state)
 			}
-		case state.chWork <- state.job:
+			c.feed(state)
+		case chWork <- state.job:
 			_ = state.next()
+		case chSpawn <- struct{}{}:
+			c.spawn(state.job)
+			state.next()
+		}
+	}
+}
+
+// spawnDue runs due jobs in new goroutines as long as semaphore is available.
+func (c *Scheduler) spawnDue(state *scheduleState) {
+	for state.chWork != nil {
+		if c.sem != nil {
+			select {
+			case c.sem <- struct{}{}:
+			default:
+				// rest of jobs wait in due jobs
+				return
+			}
 		}
+		c.spawn(state.job)
+		state.next()
 	}
 }
 
+// spawn runs job in new goroutine. semaphore must be acquired if it is not nil.
+func (c *Scheduler) spawn(j job) {
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		if c.sem != nil {
+			defer func() { <-c.sem }()
+		}
+		c.run(j)
+	}()
+}
+
 func (c *Scheduler) worker(wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer c.running.Done()
 	for {
 		select {
 		case <-c.chClose:
@@ -198,7 +822,8 @@ func (c *Scheduler) worker(wg *sync.WaitGroup) {
 		case <-c.chFin:
 			return
 		case j := <-c.chWork:
-			j.task(j.t)
+			// chWork may be shared by ShardedScheduler
+			j.c.run(j)
 		}
 	}
 }
@@ -206,10 +831,18 @@ func (c *Scheduler) worker(wg *sync.WaitGroup) {
 // ChangeWorkers will change workers size. workers must greater than 0.
 // if new size is smaller, shut appropriate number of workers down.
 // if new size is bigger, create appropriate number of workers.
+// concurrent calls are serialized and the last one decides workers size.
 func (c *Scheduler) ChangeWorkers(workers int) error {
 	if workers < 0 {
 		return ErrInvalidWorkers
 	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	select {
+	case <-c.chClose:
+		return ErrClosed
+	default:
+	}
 	for c.wNum != workers {
 		if c.wNum > workers {
 			select {
@@ -220,21 +853,31 @@ func (c *Scheduler) ChangeWorkers(workers int) error {
 			}
 		} else {
 			c.wg.Add(1)
+			c.running.Add(1)
 			go c.worker(c.wg)
 			c.wNum++
 		}
 	}
+	c.notifyWorkers(workers)
+	return nil
+}
+
+// notifyWorkers notifies scheduler that workers size have changed.
+func (c *Scheduler) notifyWorkers(workers int) {
 	select {
 	case <-c.chClose:
 	case c.chWorkers <- workers:
-		// notify scheduler that workers size have changed
 	}
-	return nil
 }
 
 // Close shutdown scheduler and workers goroutine.
+// context of running tasks set by SetContext is cancelled.
+// Close is safe to be called concurrently with ChangeWorkers, Shutdown and other Close.
 // if Scheduler is already closed then returns ErrClosed.
 func (c *Scheduler) Close() error {
+	c.abort()
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
 	for c.wNum > 0 {
 		select {
 		case <-c.chClose:
@@ -243,11 +886,17 @@ func (c *Scheduler) Close() error {
 		}
 		c.wNum--
 	}
-	select {
-	case <-c.chClose:
+	if !c.markClosed() {
 		return ErrClosed
-	default:
-		close(c.chClose)
 	}
 	return nil
 }
+
+// markClosed closes chClose. returns false if it is already closed.
+func (c *Scheduler) markClosed() bool {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return false
+	}
+	close(c.chClose)
+	return true
+}
@@ -3,7 +3,9 @@ package htask
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -136,3 +138,400 @@ func TestScheduler_ChangeWorkers(t *testing.T) {
 
 	testSchedule(0)
 }
+
+func TestScheduler_ConcurrentClose(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		var wg sync.WaitGroup
+		scheduler := NewScheduler(&wg, 2)
+		chStart := make(chan struct{})
+		var callers sync.WaitGroup
+		call := func(f func()) {
+			callers.Add(1)
+			go func() {
+				defer callers.Done()
+				<-chStart
+				f()
+			}()
+		}
+		for g := 0; g < 4; g++ {
+			call(func() {
+				for k := 0; k < 100; k++ {
+					if err := scheduler.Set(nil, time.Now(), func(_ time.Time) {}); err == ErrClosed {
+						return
+					} else if err != nil {
+						t.Errorf("Set : %v", err)
+					}
+				}
+			})
+		}
+		for g := 0; g < 2; g++ {
+			call(func() {
+				for k := 0; k < 50; k++ {
+					if err := scheduler.ChangeWorkers(k % 4); err == ErrClosed {
+						return
+					} else if err != nil {
+						t.Errorf("ChangeWorkers : %v", err)
+					}
+				}
+			})
+		}
+		var closed, shutdown int32
+		for g := 0; g < 2; g++ {
+			call(func() {
+				if scheduler.Close() == nil {
+					atomic.AddInt32(&closed, 1)
+				}
+			})
+		}
+		call(func() {
+			if _, err := scheduler.Shutdown(context.Background(), ShutdownWait); err == nil {
+				atomic.AddInt32(&shutdown, 1)
+			}
+		})
+		close(chStart)
+
+		chDone := make(chan struct{})
+		go func() {
+			callers.Wait()
+			wg.Wait()
+			close(chDone)
+		}()
+		select {
+		case <-chDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Close or ChangeWorkers does not return")
+		}
+		if closed > 1 || closed+shutdown == 0 {
+			t.Errorf("scheduler closed %v times and shut down %v times", closed, shutdown)
+		}
+	}
+}
+
+func TestScheduler_MaxConcurrency(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{MaxConcurrency: 2, Clock: clock})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	var running, maxRunning int32
+	chRelease := make(chan struct{})
+	chInfo := make(chan TaskInfo, 5)
+	for i := 0; i < 5; i++ {
+		scheduler.SetContext(nil, clock.Now(), func(ctx context.Context, info TaskInfo) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			<-chRelease
+			atomic.AddInt32(&running, -1)
+			chInfo <- info
+			return nil
+		})
+	}
+	var stats Stats
+	for i := 0; i < 100; i++ {
+		if stats, _ = scheduler.Stats(); stats.Busy == 2 && stats.Due == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats.Busy != 2 || stats.Due != 3 {
+		t.Fatalf("Busy = %v, Due = %v expected 2, 3", stats.Busy, stats.Due)
+	}
+	clock.Advance(time.Second)
+	close(chRelease)
+
+	waited := 0
+	for i := 0; i < 5; i++ {
+		select {
+		case info := <-chInfo:
+			if info.Wait == time.Second {
+				waited++
+			} else if info.Wait != 0 {
+				t.Errorf("Wait = %v expected 0 or 1s", info.Wait)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("queued task not executed")
+		}
+	}
+	if waited != 3 {
+		t.Errorf("%v tasks waited expected 3", waited)
+	}
+	if maxRunning > 2 {
+		t.Errorf("%v tasks ran at once expected 2", maxRunning)
+	}
+}
+
+func TestScheduler_Overflow(t *testing.T) {
+	ctx := context.Background()
+	newScheduler := func(policy OverflowPolicy) (*Scheduler, func()) {
+		var wg sync.WaitGroup
+		scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, MaxPending: 2, Overflow: policy})
+		return scheduler, func() {
+			scheduler.Close()
+			wg.Wait()
+		}
+	}
+
+	t.Run("block", func(t *testing.T) {
+		scheduler, closeScheduler := newScheduler(OverflowBlock)
+		defer closeScheduler()
+		chResult := make(chan int, 3)
+		now := time.Now()
+		scheduler.Set(nil, now.Add(time.Millisecond*50), mockTask{ctx: ctx, i: 0, chResult: chResult}.Task)
+		scheduler.Set(nil, now.Add(time.Hour), mockTask{ctx: ctx, i: 1, chResult: chResult}.Task)
+
+		chErr := make(chan error)
+		go func() {
+			chErr <- scheduler.Set(nil, now.Add(time.Millisecond*60), mockTask{ctx: ctx, i: 2, chResult: chResult}.Task)
+		}()
+		select {
+		case err := <-chErr:
+			t.Fatal("Set unexpectedly returned while heap is full : ", err)
+		case <-time.After(time.Millisecond * 20):
+		}
+		select {
+		case err := <-chErr:
+			if err != nil {
+				t.Errorf("blocked Set error : %v", err)
+			}
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("Set is not unblocked after job is dispatched")
+		}
+		for _, i := range []int{0, 2} {
+			select {
+			case r := <-chResult:
+				if r != i {
+					t.Errorf("result received but not euqal %v != %v", r, i)
+				}
+			case <-time.After(time.Millisecond * 100):
+				t.Fatal("task not executed : ", i)
+			}
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		scheduler, closeScheduler := newScheduler(OverflowError)
+		defer closeScheduler()
+		task := func(_ time.Time) {}
+		now := time.Now()
+		for i := 0; i < 2; i++ {
+			if err := scheduler.Set(nil, now.Add(time.Hour), task); err != nil {
+				t.Errorf("Set error : %v", err)
+			}
+		}
+		if err := scheduler.Set(nil, now.Add(time.Minute), task); err != ErrMax {
+			t.Errorf("full heap error : %v expected %v", err, ErrMax)
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		scheduler, closeScheduler := newScheduler(OverflowDropNewest)
+		defer closeScheduler()
+		chResult := make(chan int, 3)
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := scheduler.Set(nil, now.Add(time.Millisecond*time.Duration(30-i*10)), mockTask{ctx: ctx, i: i, chResult: chResult}.Task); err != nil {
+				t.Errorf("Set error : %v", err)
+			}
+		}
+		time.Sleep(time.Millisecond * 60)
+		for _, i := range []int{1, 0} {
+			if r := <-chResult; r != i {
+				t.Errorf("result received but not euqal %v != %v", r, i)
+			}
+		}
+		select {
+		case r := <-chResult:
+			t.Errorf("dropped task executed : %v", r)
+		default:
+		}
+	})
+
+	t.Run("evict latest", func(t *testing.T) {
+		scheduler, closeScheduler := newScheduler(OverflowEvictLatest)
+		defer closeScheduler()
+		chResult := make(chan int, 4)
+		now := time.Now()
+		scheduler.Set(nil, now.Add(time.Millisecond*30), mockTask{ctx: ctx, i: 0, chResult: chResult}.Task)
+		scheduler.Set(nil, now.Add(time.Millisecond*60), mockTask{ctx: ctx, i: 1, chResult: chResult}.Task)
+		// evicts 1
+		scheduler.Set(nil, now.Add(time.Millisecond*20), mockTask{ctx: ctx, i: 2, chResult: chResult}.Task)
+		// new job is the furthest then discarded
+		scheduler.Set(nil, now.Add(time.Millisecond*40), mockTask{ctx: ctx, i: 3, chResult: chResult}.Task)
+		time.Sleep(time.Millisecond * 100)
+		for _, i := range []int{2, 0} {
+			if r := <-chResult; r != i {
+				t.Errorf("result received but not euqal %v != %v", r, i)
+			}
+		}
+		select {
+		case r := <-chResult:
+			t.Errorf("evicted task executed : %v", r)
+		default:
+		}
+	})
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, MaxPending: 2, Overflow: OverflowError})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	task := func(_ time.Time) { t.Error("cancelled task executed") }
+	later := time.Now().Add(time.Hour)
+
+	// waits until cancelled jobs are removed from heap
+	setEventually := func(chCancel <-chan struct{}, task func(time.Time)) {
+		for i := 0; i < 100; i++ {
+			err := scheduler.Set(chCancel, later, task)
+			if err == nil {
+				return
+			} else if err != ErrMax {
+				t.Fatalf("Set error : %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("cancelled jobs are not removed from heap")
+	}
+
+	chCancel := make(chan struct{})
+	scheduler.Set(chCancel, later, task)
+	scheduler.Set(chCancel, later, task)
+	if err := scheduler.Set(nil, later, task); err != ErrMax {
+		t.Fatalf("full heap error : %v expected %v", err, ErrMax)
+	}
+	close(chCancel)
+	setEventually(nil, task)
+
+	// cancelled by context
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.SetContext(ctx, later, func(ctx context.Context, info TaskInfo) error {
+		t.Error("cancelled task executed")
+		return nil
+	})
+	if err := scheduler.Set(nil, later, task); err != ErrMax {
+		t.Fatalf("full heap error : %v expected %v", err, ErrMax)
+	}
+	cancel()
+	for i := 0; i < 100 && scheduler.Len() != 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := scheduler.Len(); n != 1 {
+		t.Fatalf("Len = %v expected 1", n)
+	}
+
+	h, err := scheduler.SetTask(later, task)
+	if err != nil {
+		t.Fatalf("SetTask error : %v", err)
+	}
+	if err := scheduler.Set(nil, later, task); err != ErrMax {
+		t.Fatalf("full heap error : %v expected %v", err, ErrMax)
+	}
+	h.Cancel()
+	setEventually(nil, task)
+}
+
+func TestScheduler_CancelGoroutines(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	const tasks = 1000
+	later := time.Now().Add(time.Hour)
+	task := func(ctx context.Context, info TaskInfo) error { return nil }
+	before := runtime.NumGoroutine()
+	cancels := make([]context.CancelFunc, tasks)
+	for i := range cancels {
+		var ctx context.Context
+		ctx, cancels[i] = context.WithCancel(context.Background())
+		scheduler.SetContext(ctx, later, task)
+	}
+	if n := runtime.NumGoroutine() - before; n > tasks/10 {
+		t.Errorf("%v goroutines are created for %v pending tasks", n, tasks)
+	}
+	// tasks sharing a cancel channel are watched by one goroutine
+	chCancel := make(chan struct{})
+	for i := 0; i < tasks; i++ {
+		scheduler.Set(chCancel, later, func(_ time.Time) {})
+	}
+	if n := runtime.NumGoroutine() - before; n > tasks/10 {
+		t.Errorf("%v goroutines are created for tasks sharing a cancel channel", n)
+	}
+	if n := scheduler.Len(); n != tasks*2 {
+		t.Errorf("Len = %v expected %v", n, tasks*2)
+	}
+	close(chCancel)
+	for _, cancel := range cancels {
+		cancel()
+	}
+	for i := 0; i < 100 && scheduler.Len() != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := scheduler.Len(); n != 0 {
+		t.Errorf("Len = %v expected 0", n)
+	}
+}
+
+func TestScheduler_FIFO(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewScheduler(&wg, 1)
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	const goroutines, tasks = 16, 500
+	at := time.Now().Add(time.Millisecond * 200)
+	var mu sync.Mutex
+	executed := make([][]int, goroutines)
+	chDone := make(chan struct{}, goroutines*tasks)
+
+	var setters sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		setters.Add(1)
+		go func(g int) {
+			defer setters.Done()
+			for i := 0; i < tasks; i++ {
+				i := i
+				scheduler.Set(nil, at, func(_ time.Time) {
+					mu.Lock()
+					executed[g] = append(executed[g], i)
+					mu.Unlock()
+					chDone <- struct{}{}
+				})
+			}
+		}(g)
+	}
+	setters.Wait()
+	if time.Now().After(at) {
+		t.Skip("too slow to set all tasks before they are executed")
+	}
+
+	for i := 0; i < goroutines*tasks; i++ {
+		select {
+		case <-chDone:
+		case <-time.After(time.Second * 5):
+			t.Fatal("tasks not executed")
+		}
+	}
+	for g, results := range executed {
+		for i, r := range results {
+			if r != i {
+				t.Fatalf("goroutine %v executed %v at %v", g, r, i)
+			}
+		}
+	}
+}
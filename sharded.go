@@ -0,0 +1,184 @@
+package htask
+
+import (
+	"context"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedScheduler runs multiple schedulers which have independent heap and share workers.
+// tasks are distributed to shards by round-robin or by key.
+type ShardedScheduler struct {
+	next   uint64 // accessed atomically
+	shards []*Scheduler
+	// mu serializes ChangeWorkers to notify the same size to all shards.
+	mu sync.Mutex
+}
+
+// NewShardedScheduler creates ShardedScheduler which has shards configured by option.
+// shards <= 0 means runtime.GOMAXPROCS(0). option.Workers and option.MaxConcurrency are shared by all shards
+// and option.MaxPending limits pending tasks of each shard. option.Store, option.Autoscale and option.Queues are not supported.
+// number of created goroutines is counted to sync.WaitGroup.
+func NewShardedScheduler(wg *sync.WaitGroup, shards int, option Option) *ShardedScheduler {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	if option.Workers < 0 {
+		option.Workers = 0
+	}
+	option.Store = nil
+	option.Autoscale = AutoscalePolicy{}
+	option.Queues = nil
+	chWork := make(chan job)
+	sem := newSemaphore(option.MaxConcurrency)
+	// IDs of tasks are unique among shards
+	lastID := new(uint64)
+	s := &ShardedScheduler{shards: make([]*Scheduler, shards)}
+	for i := range s.shards {
+		s.shards[i] = newScheduler(wg, option, chWork, sem, lastID)
+	}
+	// workers belong to the first shard and run jobs of all shards.
+	pool := s.shards[0]
+	for i := 0; i < option.Workers; i++ {
+		wg.Add(1)
+		pool.running.Add(1)
+		go pool.worker(wg)
+		pool.wNum++
+	}
+	return s
+}
+
+func (s *ShardedScheduler) roundRobin() *Scheduler {
+	return s.shards[atomic.AddUint64(&s.next, 1)%uint64(len(s.shards))]
+}
+
+// Shards returns number of shards.
+func (s *ShardedScheduler) Shards() int {
+	return len(s.shards)
+}
+
+func (s *ShardedScheduler) shard(key string) *Scheduler {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// Set enqueue new task to a shard chosen by round-robin. see Scheduler.Set.
+func (s *ShardedScheduler) Set(chCancel <-chan struct{}, t time.Time, task func(time.Time)) error {
+	return s.roundRobin().Set(chCancel, t, task)
+}
+
+// SetKey enqueue new task to the shard decided by key.
+// tasks of the same key keep insertion order for the same time.
+func (s *ShardedScheduler) SetKey(key string, chCancel <-chan struct{}, t time.Time, task func(time.Time)) error {
+	return s.shard(key).Set(chCancel, t, task)
+}
+
+// SetTask enqueue new task to a shard chosen by round-robin. see Scheduler.SetTask.
+func (s *ShardedScheduler) SetTask(t time.Time, task func(time.Time)) (*Handle, error) {
+	return s.roundRobin().SetTask(t, task)
+}
+
+// SetContext enqueue new task to a shard chosen by round-robin. see Scheduler.SetContext.
+func (s *ShardedScheduler) SetContext(ctx context.Context, t time.Time, task func(context.Context, TaskInfo) error) (*Handle, error) {
+	return s.roundRobin().SetContext(ctx, t, task)
+}
+
+// SetContextKey enqueue new task to the shard decided by key. see Scheduler.SetContext.
+func (s *ShardedScheduler) SetContextKey(key string, ctx context.Context, t time.Time, task func(context.Context, TaskInfo) error) (*Handle, error) {
+	return s.shard(key).SetContext(ctx, t, task)
+}
+
+// SetContextWithOption enqueue new task to a shard chosen by round-robin. see Scheduler.SetContextWithOption.
+func (s *ShardedScheduler) SetContextWithOption(ctx context.Context, t time.Time, task func(context.Context, TaskInfo) error, option TaskOption) (*Handle, error) {
+	return s.roundRobin().SetContextWithOption(ctx, t, task, option)
+}
+
+// ChangeWorkers changes size of workers shared by all shards. see Scheduler.ChangeWorkers.
+func (s *ShardedScheduler) ChangeWorkers(workers int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.shards[0].ChangeWorkers(workers); err != nil {
+		return err
+	}
+	for _, shard := range s.shards[1:] {
+		shard.notifyWorkers(workers)
+	}
+	return nil
+}
+
+// Pause stops dispatching tasks of all shards. see Scheduler.Pause.
+func (s *ShardedScheduler) Pause() error {
+	for _, shard := range s.shards {
+		if err := shard.Pause(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resume restarts dispatching tasks of all shards. see Scheduler.Resume.
+func (s *ShardedScheduler) Resume() error {
+	for _, shard := range s.shards {
+		if err := shard.Resume(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns sum of Stats of all shards. NextTime is the earliest of them.
+func (s *ShardedScheduler) Stats() (Stats, error) {
+	var total Stats
+	for i, shard := range s.shards {
+		stats, err := shard.Stats()
+		if err != nil {
+			return Stats{}, err
+		}
+		if i == 0 {
+			total.Workers = stats.Workers
+			total.Paused = stats.Paused
+		}
+		if !stats.NextTime.IsZero() && (total.NextTime.IsZero() || stats.NextTime.Before(total.NextTime)) {
+			total.NextTime = stats.NextTime
+		}
+		total.Pending += stats.Pending
+		total.Due += stats.Due
+		if stats.MaxWait > total.MaxWait {
+			total.MaxWait = stats.MaxWait
+		}
+		total.Busy += stats.Busy
+		total.Stuck += stats.Stuck
+		total.Executed += stats.Executed
+		total.Cancelled += stats.Cancelled
+		total.Dropped += stats.Dropped
+		total.Panicked += stats.Panicked
+		total.Failed += stats.Failed
+		total.TimedOut += stats.TimedOut
+		total.Retried += stats.Retried
+		total.Late += stats.Late
+		total.Misfired += stats.Misfired
+	}
+	return total, nil
+}
+
+// Len returns number of pending tasks in all shards.
+func (s *ShardedScheduler) Len() int {
+	stats, _ := s.Stats()
+	return stats.Pending
+}
+
+// Close shutdown all shards and workers.
+// if ShardedScheduler is already closed then returns ErrClosed.
+func (s *ShardedScheduler) Close() error {
+	var err error
+	for _, shard := range s.shards {
+		if e := shard.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package htask
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedScheduler(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewShardedScheduler(&wg, 4, Option{Workers: 2})
+	if n := scheduler.Shards(); n != 4 {
+		t.Errorf("Shards = %v expected 4", n)
+	}
+
+	const total = 100
+	var executed sync.WaitGroup
+	executed.Add(total * 2)
+	task := func(_ time.Time) { executed.Done() }
+	now := time.Now()
+	ids := make(map[uint64]bool)
+	for i := 0; i < total; i++ {
+		if err := scheduler.Set(nil, now.Add(time.Millisecond*time.Duration(i%10)), task); err != nil {
+			t.Fatal(err)
+		}
+		h, err := scheduler.SetContext(context.Background(), now, func(_ context.Context, _ TaskInfo) error {
+			executed.Done()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if ids[h.ID()] {
+			t.Errorf("ID %v is duplicated among shards", h.ID())
+		}
+		ids[h.ID()] = true
+	}
+	executed.Wait()
+
+	// pending tasks are distributed to all shards
+	later := now.Add(time.Hour)
+	for i := 0; i < 8; i++ {
+		scheduler.Set(nil, later, task)
+	}
+	h, _ := scheduler.SetTask(later.Add(-time.Minute), task)
+	for _, shard := range scheduler.shards {
+		if n := shard.Len(); n < 2 {
+			t.Errorf("shard has %v tasks expected >= 2", n)
+		}
+	}
+	h.Cancel()
+
+	// Executed is counted after task returns
+	var stats Stats
+	for i := 0; i < 100; i++ {
+		var err error
+		if stats, err = scheduler.Stats(); err != nil {
+			t.Fatal(err)
+		} else if stats.Executed == total*2 && stats.Busy == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expected := Stats{Pending: 8, NextTime: later, Workers: 2, Executed: total * 2, Cancelled: 1, Late: stats.Late}
+	if stats != expected {
+		t.Errorf("Stats = %+v expected %+v", stats, expected)
+	}
+
+	// goroutine is created for each task in all shards
+	if err := scheduler.ChangeWorkers(0); err != nil {
+		t.Fatal(err)
+	}
+	executed.Add(total)
+	for i := 0; i < total; i++ {
+		scheduler.Set(nil, now, task)
+	}
+	executed.Wait()
+	if stats, _ := scheduler.Stats(); stats.Workers != 0 {
+		t.Errorf("Workers = %v expected 0", stats.Workers)
+	}
+
+	if err := scheduler.Close(); err != nil {
+		t.Errorf("Close : %v", err)
+	}
+	if err := scheduler.Close(); err != ErrClosed {
+		t.Errorf("Close closed scheduler : %v expected %v", err, ErrClosed)
+	}
+	if err := scheduler.Set(nil, now, task); err != ErrClosed {
+		t.Errorf("Set to closed scheduler : %v expected %v", err, ErrClosed)
+	}
+	wg.Wait()
+}
+
+func TestShardedScheduler_Key(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewShardedScheduler(&wg, 4, Option{Workers: 1})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	const n = 100
+	at := time.Now().Add(20 * time.Millisecond)
+	var mu sync.Mutex
+	result := make(map[string][]int)
+	var executed sync.WaitGroup
+	executed.Add(len(keys) * n)
+	for i := 0; i < n; i++ {
+		for _, key := range keys {
+			key, i := key, i
+			scheduler.SetKey(key, nil, at, func(_ time.Time) {
+				mu.Lock()
+				result[key] = append(result[key], i)
+				mu.Unlock()
+				executed.Done()
+			})
+		}
+	}
+	executed.Wait()
+	for _, key := range keys {
+		for i, v := range result[key] {
+			if v != i {
+				t.Fatalf("task %v of key %v executed at %v", v, key, i)
+			}
+		}
+	}
+}
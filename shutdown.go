@@ -0,0 +1,166 @@
+package htask
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ShutdownMode decides how Shutdown treats pending tasks.
+type ShutdownMode int
+
+// shutdown modes
+const (
+	// ShutdownWait discards pending tasks and waits for running tasks.
+	ShutdownWait ShutdownMode = iota
+	// ShutdownDrain executes pending tasks which are already due,
+	// discards the others and waits for all of them.
+	ShutdownDrain
+	// ShutdownReturnPending waits for running tasks and returns pending tasks
+	// so that caller can persist or set them to other Scheduler.
+	ShutdownReturnPending
+)
+
+// PendingTask is a task which was pending when Scheduler was shut down.
+type PendingTask struct {
+	// ID is Handle.ID of the task. 0 if task is set by Set.
+	ID   uint64
+	Time time.Time
+	// Task is set if task is set by Set or SetTask.
+	Task func(time.Time)
+	// ContextTask is set if task is set by SetContext or SetNamed.
+	ContextTask func(context.Context, TaskInfo) error
+	// Name and Payload are set if task is set by SetNamed. the task can be persisted
+	// and set again by SetNamedWithOption with Option.
+	Name    string
+	Payload []byte
+	Option  TaskOption
+}
+
+type shutdown struct {
+	ctx       context.Context
+	mode      ShutdownMode
+	chPending chan<- []PendingTask
+}
+
+// Shutdown stops scheduler and waits for running tasks until ctx is done.
+// pending tasks are returned only for ShutdownReturnPending, ordered by time.
+// if ctx is done before all tasks finish then returns ctx.Err()
+// and context of running tasks set by SetContext is cancelled.
+// Scheduler is closed anyway.
+// if Scheduler is already closed then returns ErrClosed.
+func (c *Scheduler) Shutdown(ctx context.Context, mode ShutdownMode) ([]PendingTask, error) {
+	chPending := make(chan []PendingTask, 1)
+	select {
+	case <-c.chClose:
+		return nil, ErrClosed
+	case c.chShutdown <- shutdown{ctx: ctx, mode: mode, chPending: chPending}:
+	}
+	// scheduler goroutine have stopped
+	pending := <-chPending
+	c.markClosed()
+	// wait for ChangeWorkers in progress which may add workers
+	c.wmu.Lock()
+	c.wmu.Unlock()
+
+	// workers exit after running tasks finish
+	chDone := make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.running.Wait()
+		close(chDone)
+	}()
+	select {
+	case <-ctx.Done():
+		c.abort()
+		return pending, ctx.Err()
+	case <-chDone:
+		return pending, nil
+	}
+}
+
+// shutdown dispatches due jobs if needed and removes all jobs from heap.
+func (c *Scheduler) shutdown(state *scheduleState, workers int, r shutdown) []PendingTask {
+	if r.mode == ShutdownDrain {
+		c.drain(r.ctx, state, workers, c.clock.Now())
+	}
+	var pending []PendingTask
+	jobs := state.due.clear()
+	for _, q := range state.queues {
+		jobs = append(jobs, q.due.clear()...)
+	}
+	jobs = append(jobs, state.queue.clear()...)
+	sort.SliceStable(jobs, func(a, b int) bool {
+		if !jobs[a].t.Equal(jobs[b].t) {
+			return jobs[a].t.Before(jobs[b].t)
+		}
+		return jobs[a].seq < jobs[b].seq
+	})
+	for _, j := range jobs {
+		state.release(j)
+		j.discard()
+		if r.mode == ShutdownReturnPending {
+			p := PendingTask{Time: j.t, Task: j.task, ContextTask: j.ctxTask, Name: j.name, Payload: j.payload, Option: j.option()}
+			if j.h != nil {
+				p.ID = j.h.id
+			}
+			pending = append(pending, p)
+		}
+	}
+	return pending
+}
+
+// drain dispatches all pending jobs which are due at now.
+func (c *Scheduler) drain(ctx context.Context, state *scheduleState, workers int, now time.Time) {
+	state.moveDue(now, now)
+	c.feed(state)
+	for state.chWork != nil {
+		if workers == 0 {
+			if c.sem != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-c.chRetry:
+					state.add(j)
+					continue
+				case c.sem <- struct{}{}:
+				}
+			}
+			c.spawn(state.job)
+		} else {
+			select {
+			case <-ctx.Done():
+				return
+			case j := <-c.chRetry:
+				// worker retrying failed job must not block draining
+				state.add(j)
+				continue
+			case c.chWork <- state.job:
+			}
+		}
+		state.next()
+	}
+	// wait for workers of named queues to take rest of due jobs
+	for drainable(state.queues) {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-c.chRetry:
+			state.add(j)
+		case q := <-c.chPull:
+			c.pull(q)
+			c.feed(state)
+		}
+	}
+}
+
+// drainable returns true if any named queue has due jobs and workers to run them.
+func drainable(queues []*workQueue) bool {
+	for _, q := range queues {
+		if q.workers > 0 && q.due.size() > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package htask
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Shutdown(t *testing.T) {
+	// newBlocked creates Scheduler whose only worker is blocked until returned channel is closed.
+	// 3 due tasks are queued in heap and 1 task is scheduled far future.
+	newBlocked := func(chResult chan<- int) (*Scheduler, *sync.WaitGroup, chan struct{}) {
+		var wg sync.WaitGroup
+		scheduler := NewScheduler(&wg, 1)
+		chStarted, chBlock := make(chan struct{}), make(chan struct{})
+		scheduler.Set(nil, time.Now(), func(_ time.Time) {
+			close(chStarted)
+			<-chBlock
+			chResult <- -1
+		})
+		<-chStarted
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			i := i
+			scheduler.Set(nil, now.Add(time.Duration(i)), func(_ time.Time) { chResult <- i })
+		}
+		scheduler.SetTask(now.Add(time.Hour), func(_ time.Time) { chResult <- 3 })
+		time.Sleep(time.Millisecond * 10)
+		return scheduler, &wg, chBlock
+	}
+
+	t.Run("wait", func(t *testing.T) {
+		chResult := make(chan int, 10)
+		scheduler, wg, chBlock := newBlocked(chResult)
+		time.AfterFunc(time.Millisecond*10, func() { close(chBlock) })
+		pending, err := scheduler.Shutdown(context.Background(), ShutdownWait)
+		if err != nil || pending != nil {
+			t.Errorf("Shutdown = %v, %v", pending, err)
+		}
+		wg.Wait()
+		close(chResult)
+		var results []int
+		for r := range chResult {
+			results = append(results, r)
+		}
+		if len(results) != 1 || results[0] != -1 {
+			t.Errorf("results = %v expected [-1]", results)
+		}
+		if _, err := scheduler.Shutdown(context.Background(), ShutdownWait); err != ErrClosed {
+			t.Errorf("Shutdown twice error : %v expected %v", err, ErrClosed)
+		}
+		if err := scheduler.Close(); err != ErrClosed {
+			t.Errorf("Close after Shutdown error : %v expected %v", err, ErrClosed)
+		}
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		chResult := make(chan int, 10)
+		scheduler, wg, chBlock := newBlocked(chResult)
+		time.AfterFunc(time.Millisecond*10, func() { close(chBlock) })
+		if _, err := scheduler.Shutdown(context.Background(), ShutdownDrain); err != nil {
+			t.Errorf("Shutdown error : %v", err)
+		}
+		wg.Wait()
+		close(chResult)
+		var results []int
+		for r := range chResult {
+			results = append(results, r)
+		}
+		expected := []int{-1, 0, 1, 2}
+		if len(results) != len(expected) {
+			t.Fatalf("results = %v expected %v", results, expected)
+		}
+		for i := range expected {
+			if results[i] != expected[i] {
+				t.Errorf("results = %v expected %v", results, expected)
+			}
+		}
+	})
+
+	t.Run("return pending", func(t *testing.T) {
+		chResult := make(chan int, 10)
+		scheduler, wg, chBlock := newBlocked(chResult)
+		// due task of higher priority is returned in order of time
+		now := time.Now()
+		scheduler.SetContextWithOption(nil, now, func(ctx context.Context, info TaskInfo) error { return nil }, TaskOption{Priority: 1})
+		scheduler.Register("echo", func(ctx context.Context, payload []byte, info TaskInfo) error { return nil })
+		scheduler.SetNamedWithOption("echo", []byte("hello"), now.Add(time.Minute), TaskOption{Priority: 2})
+		time.Sleep(time.Millisecond * 10)
+		time.AfterFunc(time.Millisecond*10, func() { close(chBlock) })
+		pending, err := scheduler.Shutdown(context.Background(), ShutdownReturnPending)
+		if err != nil {
+			t.Errorf("Shutdown error : %v", err)
+		}
+		wg.Wait()
+		if r := <-chResult; r != -1 {
+			t.Errorf("running task = %v expected -1", r)
+		}
+		if len(pending) != 6 {
+			t.Fatalf("pending = %v expected 6 tasks", pending)
+		}
+		for i, p := range pending[:3] {
+			if p.ID != 0 {
+				t.Errorf("pending ID = %v expected 0", p.ID)
+			}
+			p.Task(p.Time)
+			if r := <-chResult; r != i {
+				t.Errorf("pending task = %v expected %v", r, i)
+			}
+		}
+		if p := pending[3]; p.ContextTask == nil || p.Option.Priority != 1 {
+			t.Errorf("pending task = %+v expected task set by SetContext", p)
+		}
+		if p := pending[4]; p.Name != "echo" || string(p.Payload) != "hello" || p.Option.Priority != 2 {
+			t.Errorf("pending task = %+v expected named task", p)
+		}
+		if pending[5].ID == 0 {
+			t.Errorf("pending task set by SetTask have no ID")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		chResult := make(chan int, 10)
+		scheduler, wg, chBlock := newBlocked(chResult)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		if _, err := scheduler.Shutdown(ctx, ShutdownWait); err != context.DeadlineExceeded {
+			t.Errorf("Shutdown error : %v expected %v", err, context.DeadlineExceeded)
+		}
+		if err := scheduler.Set(nil, time.Now(), func(_ time.Time) {}); err != ErrClosed {
+			t.Errorf("Set after Shutdown error : %v expected %v", err, ErrClosed)
+		}
+		close(chBlock)
+		wg.Wait()
+	})
+}
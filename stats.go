@@ -0,0 +1,136 @@
+package htask
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counters is updated atomically by scheduler and workers.
+type counters struct {
+	busy      int64
+	stuck     int64
+	executed  uint64
+	cancelled uint64
+	dropped   uint64
+	late      uint64
+	panicked  uint64
+	failed    uint64
+	timedOut  uint64
+	retried   uint64
+	misfired  uint64
+}
+
+// Stats is a snapshot of Scheduler.
+type Stats struct {
+	// Pending is number of tasks waiting in heap.
+	Pending int
+	// Due is number of pending tasks which are due and waiting for workers.
+	// tasks of named queues are not included. see QueueStats.
+	Due int
+	// MaxWait is how long the oldest due task counted in Due has been waiting for workers.
+	MaxWait time.Duration
+	// NextTime is the time the earliest pending task is scheduled at. zero if no task is pending.
+	NextTime time.Time
+	// Paused is true while Scheduler is paused by Pause.
+	Paused bool
+	// Workers is number of worker goroutines. 0 means a goroutine is created for each task.
+	// workers of named queues are not included.
+	Workers int
+	// Busy is number of tasks executing now.
+	Busy int
+	// Stuck is number of tasks still executing after timeout. they are not counted in Busy.
+	Stuck int
+	// Executed is number of tasks executed.
+	Executed uint64
+	// Cancelled is number of tasks cancelled before executed.
+	Cancelled uint64
+	// Dropped is number of tasks discarded by Option.Overflow.
+	Dropped uint64
+	// Panicked is number of tasks recovered from panic. they are counted in Executed too.
+	Panicked uint64
+	// Failed is number of tasks which returned error or timed out. they are counted in Executed too.
+	Failed uint64
+	// TimedOut is number of tasks exceeded timeout. they are counted in Failed too.
+	TimedOut uint64
+	// Retried is number of failed tasks enqueued again by RetryPolicy.
+	Retried uint64
+	// Late is number of tasks which became due while other due tasks were waiting for workers.
+	Late uint64
+	// Misfired is number of tasks skipped by MisfireSkip.
+	Misfired uint64
+}
+
+func (s *scheduleState) stats(workers int) Stats {
+	var next time.Time
+	if head := s.queue.peek(); head != nil {
+		next = head.t
+	}
+	var wait time.Duration
+	now := s.clock.Now()
+	for _, j := range s.due.heap {
+		if next.IsZero() || j.t.Before(next) {
+			next = j.t
+		}
+		if d := now.Sub(j.fired); d > wait {
+			wait = d
+		}
+	}
+	for _, q := range s.queues {
+		if t := q.stats(now).NextTime; !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return Stats{
+		Pending:  s.size(),
+		Due:      s.due.size(),
+		MaxWait:  wait,
+		NextTime: next,
+		Workers:  workers,
+	}
+}
+
+// Stats returns snapshot of Scheduler answered by scheduler goroutine.
+// counters are sum of all queues.
+// if Scheduler is already closed then returns ErrClosed.
+func (c *Scheduler) Stats() (Stats, error) {
+	chStats := make(chan Stats, 1)
+	select {
+	case <-c.chClose:
+		return Stats{}, ErrClosed
+	case c.chStats <- chStats:
+	}
+	stats := <-chStats
+	c.counters.addTo(&stats)
+	for _, q := range c.queues {
+		q.counters.addTo(&stats)
+	}
+	return stats, nil
+}
+
+// addTo adds counters to stats.
+func (c *counters) addTo(stats *Stats) {
+	stats.Busy += int(atomic.LoadInt64(&c.busy))
+	stats.Stuck += int(atomic.LoadInt64(&c.stuck))
+	stats.Executed += atomic.LoadUint64(&c.executed)
+	stats.Cancelled += atomic.LoadUint64(&c.cancelled)
+	stats.Dropped += atomic.LoadUint64(&c.dropped)
+	stats.Late += atomic.LoadUint64(&c.late)
+	stats.Panicked += atomic.LoadUint64(&c.panicked)
+	stats.Failed += atomic.LoadUint64(&c.failed)
+	stats.TimedOut += atomic.LoadUint64(&c.timedOut)
+	stats.Retried += atomic.LoadUint64(&c.retried)
+	stats.Misfired += atomic.LoadUint64(&c.misfired)
+}
+
+// Len returns number of pending tasks. returns 0 if Scheduler is closed.
+func (c *Scheduler) Len() int {
+	stats, _ := c.Stats()
+	return stats.Pending
+}
+
+// NextFireTime returns the time the earliest pending task is scheduled at.
+// returns zero time if no task is pending or Scheduler is closed.
+func (c *Scheduler) NextFireTime() time.Time {
+	stats, _ := c.Stats()
+	return stats.NextTime
+}
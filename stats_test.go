@@ -0,0 +1,65 @@
+package htask
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Stats(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 2, MaxPending: 3, Overflow: OverflowDropNewest})
+
+	task := func(_ time.Time) {}
+	later := time.Now().Add(time.Hour)
+
+	if stats, err := scheduler.Stats(); err != nil {
+		t.Fatalf("Stats error : %v", err)
+	} else if stats.Pending != 0 || !stats.NextTime.IsZero() || stats.Workers != 2 {
+		t.Errorf("empty stats = %+v", stats)
+	}
+
+	scheduler.Set(nil, later.Add(time.Minute), task)
+	h, _ := scheduler.SetTask(later, task)
+	if l := scheduler.Len(); l != 2 {
+		t.Errorf("Len = %v expected 2", l)
+	}
+	if next := scheduler.NextFireTime(); !next.Equal(later) {
+		t.Errorf("NextFireTime = %v expected %v", next, later)
+	}
+
+	h.Cancel()
+	chStarted, chBlock := make(chan struct{}), make(chan struct{})
+	scheduler.Set(nil, time.Now(), func(_ time.Time) {
+		close(chStarted)
+		<-chBlock
+	})
+	<-chStarted
+	scheduler.Set(nil, later, task)
+	scheduler.Set(nil, later, task)
+	// dropped
+	scheduler.Set(nil, later, task)
+
+	stats, _ := scheduler.Stats()
+	expected := Stats{Pending: 3, NextTime: later, Workers: 2, Busy: 1, Cancelled: 1, Dropped: 1}
+	if stats != expected {
+		t.Errorf("stats = %+v expected %+v", stats, expected)
+	}
+
+	close(chBlock)
+	for i := 0; i < 100; i++ {
+		if stats, _ = scheduler.Stats(); stats.Executed == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats.Executed != 1 || stats.Busy != 0 {
+		t.Errorf("executed stats = %+v", stats)
+	}
+
+	scheduler.Close()
+	wg.Wait()
+	if _, err := scheduler.Stats(); err != ErrClosed {
+		t.Errorf("closed Stats error : %v expected %v", err, ErrClosed)
+	}
+}
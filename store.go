@@ -0,0 +1,353 @@
+package htask
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// errors
+var (
+	ErrStoreVersion = errors.New("unsupported store version")
+)
+
+const (
+	storeVersion         = 1
+	snapshotFile         = "snapshot.json"
+	logFile              = "log.jsonl"
+	defaultSnapshotEvery = 1000
+)
+
+// storeEntry is a line of append only log.
+type storeEntry struct {
+	Remove bool       `json:"remove,omitempty"`
+	ID     uint64     `json:"id"`
+	Record *NamedTask `json:"record,omitempty"`
+}
+
+type snapshot struct {
+	Version int         `json:"version"`
+	Records []NamedTask `json:"records"`
+}
+
+// Store persists registered tasks to local disk as append only log and periodic snapshot.
+// a task is written when it is set and removed when it is done, failed or cancelled.
+// pending tasks are kept when Scheduler is closed and restored by the next Scheduler.
+// disk is written by a goroutine of Store so that scheduler goroutine does not wait for disk.
+type Store struct {
+	mu      sync.Mutex
+	dir     string
+	log     *os.File // written only by writer goroutine until closed
+	records map[uint64]NamedTask
+	entries int // number of log entries since last snapshot
+	every   int
+	err     error
+	closed  bool
+	// queue is log entries waiting for writer and waiters receive the result of writing them.
+	queue   []storeEntry
+	waiters []chan<- error
+	chWrite chan struct{}
+	chDone  chan struct{}
+}
+
+// OpenStore opens Store in dir and loads tasks in it.
+// snapshot is written every snapshotEvery log entries. snapshotEvery <= 0 means 1000.
+func OpenStore(dir string, snapshotEvery int) (*Store, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dir:     dir,
+		records: make(map[uint64]NamedTask),
+		every:   snapshotEvery,
+		chWrite: make(chan struct{}, 1),
+		chDone:  make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	// compact loaded log into snapshot and start new log
+	if err := s.snapshot(s.sorted()); err != nil {
+		return nil, err
+	}
+	go s.writer()
+	return s, nil
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFile))
+	if err == nil {
+		var snap snapshot
+		err = json.NewDecoder(f).Decode(&snap)
+		f.Close()
+		if err != nil {
+			return err
+		} else if snap.Version != storeVersion {
+			return ErrStoreVersion
+		}
+		for _, r := range snap.Records {
+			s.records[r.ID] = r
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err = os.Open(filepath.Join(s.dir, logFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// last line without newline is partially written by crash
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var entry storeEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		if entry.Remove {
+			delete(s.records, entry.ID)
+		} else if entry.Record != nil {
+			s.records[entry.ID] = *entry.Record
+		}
+	}
+}
+
+// snapshot writes records to snapshot file and truncates log.
+func (s *Store) snapshot(records []NamedTask) error {
+	path := filepath.Join(s.dir, snapshotFile)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(snapshot{Version: storeVersion, Records: records})
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log, err = os.OpenFile(filepath.Join(s.dir, logFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (s *Store) sorted() []NamedTask {
+	records := make([]NamedTask, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sortRecords(records)
+	return records
+}
+
+func sortRecords(records []NamedTask) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Time.Equal(records[j].Time) {
+			return records[i].Time.Before(records[j].Time)
+		}
+		return records[i].ID < records[j].ID
+	})
+}
+
+// writer writes queued entries to disk until Store is closed.
+func (s *Store) writer() {
+	defer close(s.chDone)
+	for range s.chWrite {
+		s.flush()
+	}
+	// entries queued before closed
+	s.flush()
+}
+
+// flush writes queued entries to log. snapshot is written instead if log is long enough.
+func (s *Store) flush() {
+	s.mu.Lock()
+	entries, waiters := s.queue, s.waiters
+	s.queue, s.waiters = nil, nil
+	var records []NamedTask
+	if len(entries) > 0 && s.entries+len(entries) >= s.every {
+		// records already reflect all queued entries
+		records = make([]NamedTask, 0, len(s.records))
+		for _, r := range s.records {
+			records = append(records, r)
+		}
+		s.entries = 0
+	} else {
+		s.entries += len(entries)
+	}
+	s.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	var err error
+	if records != nil {
+		sortRecords(records)
+		err = s.snapshot(records)
+	} else {
+		var buf []byte
+		for _, entry := range entries {
+			b, e := json.Marshal(entry)
+			if e != nil {
+				err = e
+				break
+			}
+			buf = append(append(buf, b...), '\n')
+		}
+		if err == nil {
+			_, err = s.log.Write(buf)
+		}
+	}
+	if err != nil {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+	}
+	for _, chErr := range waiters {
+		chErr <- err
+	}
+}
+
+// enqueue queues entry for writer. chErr receives the result of writing if it is not nil.
+// s.mu must be held.
+func (s *Store) enqueue(entry storeEntry, chErr chan<- error) error {
+	if s.closed {
+		return ErrClosed
+	} else if s.err != nil {
+		return s.err
+	}
+	s.queue = append(s.queue, entry)
+	if chErr != nil {
+		s.waiters = append(s.waiters, chErr)
+	}
+	select {
+	case s.chWrite <- struct{}{}:
+	default:
+		// writer is already notified
+	}
+	return nil
+}
+
+// set writes new or updated record and waits until it is written.
+func (s *Store) set(r NamedTask) error {
+	chErr := make(chan error, 1)
+	s.setAsync(r, chErr)
+	return <-chErr
+}
+
+// add writes new record and waits until it is written.
+// returns ErrDuplicateID if record of the same ID exists.
+func (s *Store) add(r NamedTask) error {
+	chErr := make(chan error, 1)
+	s.mu.Lock()
+	if _, ok := s.records[r.ID]; ok {
+		s.mu.Unlock()
+		return ErrDuplicateID
+	}
+	if err := s.enqueue(storeEntry{ID: r.ID, Record: &r}, chErr); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.records[r.ID] = r
+	s.mu.Unlock()
+	return <-chErr
+}
+
+// setAsync writes new or updated record without waiting.
+// chErr must be buffered and receives the result once.
+func (s *Store) setAsync(r NamedTask, chErr chan<- error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enqueue(storeEntry{ID: r.ID, Record: &r}, chErr); err != nil {
+		chErr <- err
+		return
+	}
+	s.records[r.ID] = r
+}
+
+// remove removes record without waiting. error is kept and returned by Err.
+func (s *Store) remove(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[id]; !ok {
+		return
+	}
+	delete(s.records, id)
+	_ = s.enqueue(storeEntry{Remove: true, ID: id}, nil)
+}
+
+// pending returns all records ordered by time.
+func (s *Store) pending() []NamedTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted()
+}
+
+// Len returns number of tasks in Store.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Err returns the first error occurred on writing to disk.
+func (s *Store) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close writes snapshot and closes Store.
+// Store should be closed after all goroutines of Scheduler using it are finished.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.closed = true
+	close(s.chWrite)
+	s.mu.Unlock()
+	// wait for entries queued before closed
+	<-s.chDone
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.err
+	if err == nil {
+		err = s.snapshot(s.sorted())
+	}
+	if s.log != nil {
+		if e := s.log.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
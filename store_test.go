@@ -0,0 +1,209 @@
+package htask
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Store(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	chResult := make(chan string, 10)
+	echo := func(ctx context.Context, payload []byte, info TaskInfo) error {
+		chResult <- string(payload)
+		return nil
+	}
+
+	store, err := OpenStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock, Store: store})
+	if _, err := scheduler.SetNamed("echo", nil, clock.Now()); err != ErrUnknownTask {
+		t.Errorf("SetNamed unknown task : %v expected %v", err, ErrUnknownTask)
+	}
+	if err := scheduler.Register("echo", echo); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.SetNamed("echo", []byte("now"), clock.Now())
+	select {
+	case p := <-chResult:
+		if p != "now" {
+			t.Errorf("payload = %v expected now", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("named task not executed")
+	}
+	later := clock.Now().Add(time.Hour)
+	h, _ := scheduler.SetNamed("echo", []byte("cancelled"), later)
+	h.Cancel()
+	h, _ = scheduler.SetNamedWithOption("echo", []byte("later"), later.Add(time.Hour), TaskOption{Priority: 1})
+	h.Reschedule(later)
+	lastID := h.ID()
+
+	scheduler.Close()
+	wg.Wait()
+	if n := store.Len(); n != 1 {
+		t.Errorf("Store.Len = %v expected 1", n)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart
+	store, err = OpenStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler = NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock, Store: store, Handlers: map[string]TaskHandler{"echo": echo}})
+	if n := scheduler.Len(); n != 1 {
+		t.Errorf("restored Len = %v expected 1", n)
+	}
+	if next := scheduler.NextFireTime(); !next.Equal(later) {
+		t.Errorf("restored NextFireTime = %v expected %v", next, later)
+	}
+	h, _ = scheduler.SetNamed("echo", []byte("new"), later.Add(time.Hour))
+	if h.ID() <= lastID {
+		t.Errorf("new ID %v is not bigger than restored ID %v", h.ID(), lastID)
+	}
+	clock.Advance(time.Hour)
+	select {
+	case p := <-chResult:
+		if p != "later" {
+			t.Errorf("payload = %v expected later", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restored task not executed")
+	}
+	// Store is updated after task returned
+	for i := 0; i < 100 && store.Len() != 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := store.Len(); n != 1 {
+		t.Errorf("Store.Len = %v expected 1", n)
+	}
+	scheduler.Close()
+	wg.Wait()
+	store.Close()
+}
+
+func TestScheduler_StoreUnregistered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	chResult := make(chan string, 10)
+	handlers := map[string]TaskHandler{"echo": func(ctx context.Context, payload []byte, info TaskInfo) error {
+		chResult <- string(payload)
+		return nil
+	}}
+	open := func(handlers map[string]TaskHandler, onDead func(TaskInfo, error)) (*Scheduler, func(stored int)) {
+		store, err := OpenStore(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock, Store: store, Handlers: handlers, DeadLetterHandler: onDead})
+		return scheduler, func(stored int) {
+			scheduler.Close()
+			wg.Wait()
+			if n := store.Len(); n != stored {
+				t.Errorf("Store.Len = %v expected %v", n, stored)
+			}
+			store.Close()
+		}
+	}
+
+	scheduler, closeScheduler := open(handlers, nil)
+	scheduler.SetNamed("echo", []byte("later"), clock.Now().Add(time.Hour))
+	closeScheduler(1)
+	// task is due while stopped
+	clock.Advance(time.Hour * 2)
+
+	// restart without handler
+	chDead := make(chan error, 1)
+	scheduler, closeScheduler = open(nil, func(info TaskInfo, err error) { chDead <- err })
+	select {
+	case err := <-chDead:
+		if err != ErrUnknownTask {
+			t.Errorf("dead letter error = %v expected %v", err, ErrUnknownTask)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restored task not executed")
+	}
+	// task is kept in Store
+	closeScheduler(1)
+
+	_, closeScheduler = open(handlers, nil)
+	select {
+	case p := <-chResult:
+		if p != "later" {
+			t.Errorf("payload = %v expected later", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restored task not executed")
+	}
+	closeScheduler(0)
+}
+
+func TestStore_Recovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store, err := OpenStore(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(1); i <= 5; i++ {
+		if err := store.set(NamedTask{ID: i, Name: "task", Time: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.remove(2)
+	store.set(NamedTask{ID: 4, Name: "task", Time: now, Attempt: 1})
+	// crash while writing log without Close
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":1,"remo`)
+	f.Close()
+
+	recovered, err := OpenStore(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	records := recovered.pending()
+	expected := []uint64{4, 1, 3, 5}
+	if len(records) != len(expected) {
+		t.Fatalf("recovered %v records expected %v", len(records), len(expected))
+	}
+	for i, r := range records {
+		if r.ID != expected[i] {
+			t.Errorf("record %v is %v expected %v", i, r.ID, expected[i])
+		}
+	}
+	if records[0].Attempt != 1 {
+		t.Errorf("updated record is not recovered : %+v", records[0])
+	}
+}
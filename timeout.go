@@ -0,0 +1,60 @@
+package htask
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// states of task executed with timeout
+const (
+	execRunning int32 = iota
+	execFinished
+	execTimedOut
+)
+
+// execTimeout executes task with timeout of the task or Option.TaskTimeout.
+// when task times out, its context is cancelled and worker is freed
+// leaving the task running in another goroutine as stuck task.
+func (c *Scheduler) execTimeout(ctx context.Context, cancel context.CancelFunc, j job) error {
+	timeout := j.timeout
+	if timeout == 0 {
+		timeout = c.timeout
+	}
+	if timeout <= 0 {
+		return c.exec(ctx, j)
+	}
+
+	counters := c.countersOf(&j)
+	var state int32
+	chErr := make(chan error, 1)
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		err := c.exec(ctx, j)
+		if !atomic.CompareAndSwapInt32(&state, execRunning, execFinished) {
+			// worker have given up the task
+			atomic.AddInt64(&counters.stuck, -1)
+		}
+		chErr <- err
+	}()
+
+	timer := c.clock.NewTimer(timeout)
+	select {
+	case err := <-chErr:
+		timer.Stop()
+		return err
+	case <-timer.C():
+	}
+	atomic.AddInt64(&counters.stuck, 1)
+	if !atomic.CompareAndSwapInt32(&state, execRunning, execTimedOut) {
+		// finished just before timeout
+		atomic.AddInt64(&counters.stuck, -1)
+		return <-chErr
+	}
+	cancel()
+	atomic.AddUint64(&counters.timedOut, 1)
+	if c.onTimeout != nil {
+		c.onTimeout(j.info())
+	}
+	return ErrTaskTimeout
+}
@@ -0,0 +1,85 @@
+package htask
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_TaskTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	chTimeout := make(chan TaskInfo, 2)
+	scheduler := NewSchedulerWithOption(&wg, Option{
+		Workers:        1,
+		TaskTimeout:    time.Millisecond * 20,
+		TimeoutHandler: func(info TaskInfo) { chTimeout <- info },
+	})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	// task ignores its context and blocks worker
+	chBlock, chCtxErr := make(chan struct{}), make(chan error, 1)
+	h, _ := scheduler.SetContextWithOption(context.Background(), time.Now(), func(ctx context.Context, _ TaskInfo) error {
+		<-ctx.Done()
+		chCtxErr <- ctx.Err()
+		<-chBlock
+		return nil
+	}, TaskOption{Timeout: time.Millisecond * 10})
+
+	select {
+	case info := <-chTimeout:
+		if info.ID != h.ID() {
+			t.Errorf("timeout info = %+v expected ID %v", info, h.ID())
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("timeout is not reported")
+	}
+	if err := <-chCtxErr; err != context.Canceled {
+		t.Errorf("context error = %v expected %v", err, context.Canceled)
+	}
+	for i := 0; i < 100 && h.Status() == StateRunning; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if s, err := h.Status(), h.Err(); s != StateFailed || err != ErrTaskTimeout {
+		t.Errorf("status = %v, %v expected %v, %v", s, err, StateFailed, ErrTaskTimeout)
+	}
+
+	// worker is freed while stuck task is running
+	chResult := make(chan struct{})
+	scheduler.Set(nil, time.Now(), func(_ time.Time) { close(chResult) })
+	select {
+	case <-chResult:
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("worker is not freed after timeout")
+	}
+
+	stats, _ := scheduler.Stats()
+	if stats.Stuck != 1 || stats.TimedOut != 1 || stats.Failed != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	close(chBlock)
+	for i := 0; i < 100; i++ {
+		if stats, _ = scheduler.Stats(); stats.Stuck == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats.Stuck != 0 {
+		t.Errorf("stuck task is not released : %+v", stats)
+	}
+
+	// default timeout is applied to tasks set by Set
+	scheduler.Set(nil, time.Now(), func(_ time.Time) { time.Sleep(time.Millisecond * 50) })
+	select {
+	case info := <-chTimeout:
+		if info.ID != 0 {
+			t.Errorf("timeout info = %+v expected ID 0", info)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("default timeout is not reported")
+	}
+}
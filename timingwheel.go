@@ -0,0 +1,354 @@
+package htask
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// queue holds pending jobs which are not due yet.
+type queue interface {
+	add(j *job) error
+	addMany(jobs []*job) error
+	// remove removes j. returns false if j is not in queue.
+	remove(j *job) bool
+	// fix re-establishes ordering after time of j is changed.
+	fix(j *job)
+	// peek returns the earliest job.
+	peek() *job
+	// next returns the time timer should expire at.
+	next() (time.Time, bool)
+	// popDue pops a job which is due at now. returns nil if no job is due.
+	popDue(now time.Time) *job
+	// latest returns the job which has the furthest time.
+	latest() *job
+	size() int
+	// clear removes all jobs and returns them ordered by time.
+	clear() []*job
+	// each calls f for all jobs in any order.
+	each(f func(j *job))
+}
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelMask   = wheelSlots - 1
+	wheelLevels = 6
+)
+
+// wheelBucket is a slot of timingWheel. index of job is the position in jobs.
+type wheelBucket struct {
+	jobs []*job
+}
+
+func (b *wheelBucket) add(j *job) {
+	j.bucket = b
+	j.index = len(b.jobs)
+	b.jobs = append(b.jobs, j)
+}
+
+func (b *wheelBucket) remove(j *job) {
+	last := b.jobs[len(b.jobs)-1]
+	b.jobs[j.index] = last
+	last.index = j.index
+	b.jobs[len(b.jobs)-1] = nil
+	b.jobs = b.jobs[:len(b.jobs)-1]
+	j.bucket = nil
+	j.index = -1
+}
+
+// timingWheel is hierarchical timing wheel. time of job is rounded up to tick.
+// level i has 64 slots of 64^i ticks and holds jobs in the same 64^(i+1) ticks as cursor.
+// jobs beyond the last level wait in min heap.
+type timingWheel struct {
+	tick     time.Duration
+	origin   time.Time
+	cursor   int64 // ticks from origin which are already expired
+	levels   [wheelLevels][wheelSlots]wheelBucket
+	occupied [wheelLevels]uint64 // bit of slot may be set even if the slot is empty.
+	due      wheelBucket
+	far      *minHeap
+	count    int
+}
+
+func newTimingWheel(tick time.Duration, origin time.Time) *timingWheel {
+	return &timingWheel{
+		tick:   tick,
+		origin: origin,
+		far:    newMinHeap(0),
+	}
+}
+
+// tickOf returns the first tick at which t is due.
+func (w *timingWheel) tickOf(t time.Time) int64 {
+	d := t.Sub(w.origin)
+	n := int64(d / w.tick)
+	if d%w.tick > 0 {
+		n++
+	}
+	return n
+}
+
+// floorTick returns the last tick expired at t.
+func (w *timingWheel) floorTick(t time.Time) int64 {
+	d := t.Sub(w.origin)
+	n := int64(d / w.tick)
+	if d%w.tick < 0 {
+		n--
+	}
+	return n
+}
+
+func (w *timingWheel) tickTime(n int64) time.Time {
+	if n > int64(math.MaxInt64/w.tick) {
+		return w.origin.Add(math.MaxInt64)
+	}
+	return w.origin.Add(time.Duration(n) * w.tick)
+}
+
+func (w *timingWheel) add(j *job) error {
+	w.place(j)
+	w.count++
+	return nil
+}
+
+func (w *timingWheel) addMany(jobs []*job) error {
+	for _, j := range jobs {
+		w.place(j)
+	}
+	w.count += len(jobs)
+	return nil
+}
+
+// place puts j into the slot decided by cursor.
+func (w *timingWheel) place(j *job) {
+	n := w.tickOf(j.t)
+	if n <= w.cursor {
+		w.due.add(j)
+		return
+	}
+	for i := 0; i < wheelLevels; i++ {
+		if n>>uint(wheelBits*(i+1)) == w.cursor>>uint(wheelBits*(i+1)) {
+			slot := (n >> uint(wheelBits*i)) & wheelMask
+			w.levels[i][slot].add(j)
+			w.occupied[i] |= 1 << uint(slot)
+			return
+		}
+	}
+	j.bucket = nil
+	_ = w.far.add(j)
+}
+
+func (w *timingWheel) remove(j *job) bool {
+	b := j.bucket
+	if b == nil {
+		if !w.far.remove(j) {
+			return false
+		}
+	} else {
+		if j.index < 0 || j.index >= len(b.jobs) || b.jobs[j.index] != j {
+			return false
+		}
+		b.remove(j)
+	}
+	w.count--
+	return true
+}
+
+func (w *timingWheel) fix(j *job) {
+	if w.remove(j) {
+		_ = w.add(j)
+	}
+}
+
+// first returns the first non empty slot and the tick the slot starts.
+func (w *timingWheel) first() (*wheelBucket, int64) {
+	if len(w.due.jobs) > 0 {
+		return &w.due, w.cursor
+	}
+	for i := 0; i < wheelLevels; i++ {
+		shift := uint(wheelBits * i)
+		current := uint((w.cursor >> shift) & wheelMask)
+		// slots after current slot. shift by 64 is 0.
+		m := w.occupied[i] & (^uint64(0) << (current + 1))
+		for m != 0 {
+			slot := uint(bits.TrailingZeros64(m))
+			b := &w.levels[i][slot]
+			if len(b.jobs) == 0 {
+				w.occupied[i] &^= 1 << slot
+				m &^= 1 << slot
+				continue
+			}
+			block := w.cursor >> (shift + wheelBits) << (shift + wheelBits)
+			return b, block | int64(slot)<<shift
+		}
+	}
+	return nil, 0
+}
+
+func (w *timingWheel) peek() *job {
+	b, _ := w.first()
+	if b == nil {
+		return w.far.peek()
+	}
+	earliest := b.jobs[0]
+	for _, j := range b.jobs[1:] {
+		if j.t.Before(earliest.t) || (j.t.Equal(earliest.t) && j.seq < earliest.seq) {
+			earliest = j
+		}
+	}
+	return earliest
+}
+
+// nextTick returns the tick cursor has to stop at next.
+func (w *timingWheel) nextTick() (int64, bool) {
+	if b, n := w.first(); b != nil {
+		return n, true
+	}
+	if head := w.far.peek(); head != nil {
+		return w.tickOf(head.t), true
+	}
+	return 0, false
+}
+
+func (w *timingWheel) next() (time.Time, bool) {
+	n, ok := w.nextTick()
+	if !ok {
+		return time.Time{}, false
+	}
+	return w.tickTime(n), true
+}
+
+func (w *timingWheel) popDue(now time.Time) *job {
+	if len(w.due.jobs) == 0 {
+		w.advance(w.floorTick(now))
+	}
+	if len(w.due.jobs) == 0 {
+		return nil
+	}
+	j := w.due.jobs[len(w.due.jobs)-1]
+	w.due.remove(j)
+	w.count--
+	return j
+}
+
+// advance moves cursor toward target until any job becomes due.
+func (w *timingWheel) advance(target int64) {
+	for len(w.due.jobs) == 0 {
+		n, ok := w.nextTick()
+		if !ok || n > target {
+			if target > w.cursor {
+				w.moveCursor(target)
+			}
+			return
+		}
+		w.moveCursor(n)
+	}
+}
+
+// moveCursor moves cursor to n and cascades the slots n enters.
+// all slots between cursor and n must be empty.
+func (w *timingWheel) moveCursor(n int64) {
+	prev := w.cursor
+	w.cursor = n
+	top := uint(wheelBits * wheelLevels)
+	if prev>>top != n>>top {
+		for head := w.far.peek(); head != nil && w.tickOf(head.t)>>top == n>>top; head = w.far.peek() {
+			w.far.pop()
+			w.place(head)
+		}
+	}
+	for i := wheelLevels - 1; i >= 0; i-- {
+		shift := uint(wheelBits * i)
+		if prev>>shift == n>>shift {
+			continue
+		}
+		b := &w.levels[i][(n>>shift)&wheelMask]
+		jobs := b.jobs
+		b.jobs = nil
+		for _, j := range jobs {
+			w.place(j)
+		}
+	}
+}
+
+// last returns the last non empty slot. jobs in upper level are later than all jobs in lower levels.
+func (w *timingWheel) last() *wheelBucket {
+	for i := wheelLevels - 1; i >= 0; i-- {
+		current := uint((w.cursor >> uint(wheelBits*i)) & wheelMask)
+		// slots after current slot. shift by 64 is 0.
+		m := w.occupied[i] & (^uint64(0) << (current + 1))
+		for m != 0 {
+			slot := uint(63 - bits.LeadingZeros64(m))
+			b := &w.levels[i][slot]
+			if len(b.jobs) == 0 {
+				w.occupied[i] &^= 1 << slot
+				m &^= 1 << slot
+				continue
+			}
+			return b
+		}
+	}
+	if len(w.due.jobs) > 0 {
+		return &w.due
+	}
+	return nil
+}
+
+// latest searches only the furthest slot unless far heap has jobs.
+func (w *timingWheel) latest() *job {
+	if latest := w.far.latest(); latest != nil {
+		return latest
+	}
+	b := w.last()
+	if b == nil {
+		return nil
+	}
+	latest := b.jobs[0]
+	for _, j := range b.jobs[1:] {
+		if latest.t.Before(j.t) {
+			latest = j
+		}
+	}
+	return latest
+}
+
+func (w *timingWheel) size() int {
+	return w.count
+}
+
+func (w *timingWheel) clear() []*job {
+	jobs := make([]*job, 0, w.count)
+	w.each(func(j *job) {
+		j.bucket = nil
+		j.index = -1
+		jobs = append(jobs, j)
+	})
+	sort.Slice(jobs, func(a, b int) bool {
+		if !jobs[a].t.Equal(jobs[b].t) {
+			return jobs[a].t.Before(jobs[b].t)
+		}
+		return jobs[a].seq < jobs[b].seq
+	})
+	w.far = newMinHeap(0)
+	w.levels = [wheelLevels][wheelSlots]wheelBucket{}
+	w.occupied = [wheelLevels]uint64{}
+	w.due = wheelBucket{}
+	w.count = 0
+	return jobs
+}
+
+func (w *timingWheel) each(f func(j *job)) {
+	w.far.each(f)
+	for _, j := range w.due.jobs {
+		f(j)
+	}
+	for i := range w.levels {
+		for k := range w.levels[i] {
+			for _, j := range w.levels[i][k].jobs {
+				f(j)
+			}
+		}
+	}
+}
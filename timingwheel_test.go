@@ -0,0 +1,189 @@
+package htask
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimingWheel(t *testing.T) {
+	origin := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := time.Millisecond
+
+	t.Run("pop due", func(t *testing.T) {
+		w := newTimingWheel(tick, origin)
+		r := rand.New(rand.NewSource(1))
+		jobs := make(map[*job]struct{})
+		for i := 0; i < 1000; i++ {
+			// spread over all levels and far heap
+			d := time.Duration(r.Int63n(int64(1 << uint(r.Intn(50)))))
+			j := &job{t: origin.Add(d - time.Second), seq: uint64(i)}
+			w.add(j)
+			jobs[j] = struct{}{}
+		}
+		if w.size() != len(jobs) {
+			t.Fatalf("size = %v expected %v", w.size(), len(jobs))
+		}
+		now := origin
+		for len(jobs) > 0 {
+			next, ok := w.next()
+			if !ok {
+				t.Fatalf("next not found but %v jobs remain", len(jobs))
+			}
+			if next.Before(now) {
+				next = now
+			}
+			earliest := w.peek()
+			for j := range jobs {
+				if j.t.Before(earliest.t) {
+					t.Fatalf("peek = %v but %v exists", earliest.t, j.t)
+				}
+			}
+			now = next
+			for j := w.popDue(now); j != nil; j = w.popDue(now) {
+				if j.t.After(now) {
+					t.Fatalf("job of %v popped at %v", j.t, now)
+				}
+				if _, ok := jobs[j]; !ok {
+					t.Fatalf("unknown job popped")
+				}
+				delete(jobs, j)
+			}
+			for j := range jobs {
+				if !w.tickTime(w.tickOf(j.t)).After(now) {
+					t.Fatalf("job of %v is not popped at %v", j.t, now)
+				}
+			}
+		}
+		if w.size() != 0 {
+			t.Errorf("size = %v expected 0", w.size())
+		}
+		if _, ok := w.next(); ok {
+			t.Errorf("next found in empty wheel")
+		}
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		w := newTimingWheel(tick, origin)
+		r := rand.New(rand.NewSource(1))
+		jobs := make(map[*job]struct{})
+		for i := 0; i < 1000; i++ {
+			// all jobs are in wheel
+			d := time.Duration(r.Int63n(int64(1 << uint(r.Intn(35)))))
+			j := &job{t: origin.Add(d * tick), seq: uint64(i)}
+			w.add(j)
+			jobs[j] = struct{}{}
+		}
+		now := origin
+		for len(jobs) > 0 {
+			var expected *job
+			for j := range jobs {
+				if expected == nil || expected.t.Before(j.t) {
+					expected = j
+				}
+			}
+			if latest := w.latest(); latest == nil || !latest.t.Equal(expected.t) {
+				t.Fatalf("latest = %v expected %v", latest, expected.t)
+			}
+			w.remove(expected)
+			delete(jobs, expected)
+			// move cursor to cascade jobs
+			now = now.Add(time.Duration(r.Int63n(int64(time.Hour))))
+			for j := w.popDue(now); j != nil; j = w.popDue(now) {
+				delete(jobs, j)
+			}
+		}
+		if latest := w.latest(); latest != nil {
+			t.Errorf("latest of empty wheel = %v", latest.t)
+		}
+	})
+
+	t.Run("remove and fix", func(t *testing.T) {
+		w := newTimingWheel(tick, origin)
+		jobs := make([]*job, 100)
+		for i := range jobs {
+			jobs[i] = &job{t: origin.Add(time.Duration(i) * time.Hour * 24), seq: uint64(i)}
+			w.add(jobs[i])
+		}
+		for i := 0; i < len(jobs); i += 2 {
+			if !w.remove(jobs[i]) {
+				t.Errorf("remove %v failed", i)
+			}
+		}
+		if w.remove(jobs[0]) {
+			t.Errorf("removed job is removed again")
+		}
+		if latest := w.latest(); latest != jobs[99] {
+			t.Errorf("latest = %v expected %v", latest.t, jobs[99].t)
+		}
+		jobs[99].t = origin.Add(time.Second)
+		w.fix(jobs[99])
+		if peek := w.peek(); peek != jobs[99] {
+			t.Errorf("peek = %v expected %v", peek.t, jobs[99].t)
+		}
+		if j := w.popDue(origin.Add(time.Second - tick)); j != nil {
+			t.Errorf("job of %v popped before its tick", j.t)
+		}
+		if j := w.popDue(origin.Add(time.Second)); j != jobs[99] {
+			t.Errorf("fixed job is not popped")
+		}
+
+		cleared := w.clear()
+		if len(cleared) != 49 || w.size() != 0 {
+			t.Fatalf("cleared %v jobs and %v remain", len(cleared), w.size())
+		}
+		for i, j := range cleared {
+			if j != jobs[i*2+1] {
+				t.Errorf("cleared %v is %v expected %v", i, j.t, jobs[i*2+1].t)
+			}
+		}
+	})
+}
+
+func TestScheduler_TimingWheel(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Clock: clock, WheelTick: time.Second})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	chResult := make(chan time.Time, 10)
+	task := func(ts time.Time) { chResult <- ts }
+	start := clock.Now()
+	scheduler.Set(nil, start.Add(time.Hour+time.Millisecond), task)
+	scheduler.Set(nil, start.Add(1500*time.Millisecond), task)
+
+	if next := scheduler.NextFireTime(); !next.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("NextFireTime = %v expected %v", next, start.Add(1500*time.Millisecond))
+	}
+	clock.Advance(1500 * time.Millisecond)
+	select {
+	case ts := <-chResult:
+		t.Fatalf("task executed at %v before tick", ts)
+	case <-time.After(time.Millisecond * 10):
+	}
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case ts := <-chResult:
+		if expected := start.Add(2 * time.Second); !ts.Equal(expected) {
+			t.Errorf("fired = %v expected %v", ts, expected)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task not executed at tick")
+	}
+	clock.Advance(time.Hour - time.Second)
+	select {
+	case ts := <-chResult:
+		if expected := start.Add(time.Hour + time.Second); !ts.Equal(expected) {
+			t.Errorf("fired = %v expected %v", ts, expected)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task not executed at tick")
+	}
+	if n := scheduler.Len(); n != 0 {
+		t.Errorf("Len = %v expected 0", n)
+	}
+}
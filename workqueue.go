@@ -0,0 +1,203 @@
+package htask
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// errors
+var (
+	ErrUnknownQueue = errors.New("queue is not defined")
+)
+
+// workQueue is a named queue which has its own due jobs and workers.
+// jobs of all queues share the heap of scheduler and are moved to due jobs of their queue.
+// fields except counters are accessed only by scheduler goroutine.
+type workQueue struct {
+	name string
+	due  *minHeap // jobs which are due and waiting for worker of this queue
+	// chNext passes a due job to the worker which pulled. nil stops the worker.
+	chNext   chan *job
+	workers  int // number of workers which keep running
+	idle     int // number of workers waiting on chNext
+	stopping int // number of workers to stop when they pull next time
+	counters counters
+}
+
+func newWorkQueue(name string) *workQueue {
+	return &workQueue{name: name, due: newPriorityHeap(0), chNext: make(chan *job)}
+}
+
+type queueWorkers struct {
+	q       *workQueue
+	workers int
+}
+
+type queueStats struct {
+	q       *workQueue
+	chStats chan<- Stats
+}
+
+// Queues returns sorted names of queues defined by Option.Queues.
+func (c *Scheduler) Queues() []string {
+	names := make([]string, 0, len(c.queues))
+	for name := range c.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queue returns named queue. empty name means default queue which is nil.
+func (c *Scheduler) queue(name string) (*workQueue, error) {
+	if name == "" {
+		return nil, nil
+	}
+	q, ok := c.queues[name]
+	if !ok {
+		return nil, ErrUnknownQueue
+	}
+	return q, nil
+}
+
+// ChangeQueueWorkers changes number of workers of the named queue.
+// tasks of a queue which has no worker wait until workers are added.
+// if name is not defined by Option.Queues then returns ErrUnknownQueue.
+func (c *Scheduler) ChangeQueueWorkers(name string, workers int) error {
+	if workers < 0 {
+		return ErrInvalidWorkers
+	}
+	q, ok := c.queues[name]
+	if !ok {
+		return ErrUnknownQueue
+	}
+	select {
+	case <-c.chClose:
+		return ErrClosed
+	case c.chQueueWorkers <- queueWorkers{q: q, workers: workers}:
+		return nil
+	}
+}
+
+// QueueStats returns snapshot of the named queue. Pending and Due are number of due tasks
+// waiting for workers of the queue, and counters are of tasks executed by the queue.
+// if name is not defined by Option.Queues then returns ErrUnknownQueue.
+func (c *Scheduler) QueueStats(name string) (Stats, error) {
+	q, ok := c.queues[name]
+	if !ok {
+		return Stats{}, ErrUnknownQueue
+	}
+	chStats := make(chan Stats, 1)
+	select {
+	case <-c.chClose:
+		return Stats{}, ErrClosed
+	case c.chQueueStats <- queueStats{q: q, chStats: chStats}:
+	}
+	stats := <-chStats
+	q.counters.addTo(&stats)
+	return stats, nil
+}
+
+func (q *workQueue) stats(now time.Time) Stats {
+	stats := Stats{Pending: q.due.size(), Due: q.due.size(), Workers: q.workers}
+	q.due.each(func(j *job) {
+		if stats.NextTime.IsZero() || j.t.Before(stats.NextTime) {
+			stats.NextTime = j.t
+		}
+		if d := now.Sub(j.fired); d > stats.MaxWait {
+			stats.MaxWait = d
+		}
+	})
+	return stats
+}
+
+// changeQueueWorkers starts or stops workers of q. it is called by scheduler goroutine.
+func (c *Scheduler) changeQueueWorkers(q *workQueue, workers int) {
+	for q.workers < workers {
+		if q.stopping > 0 {
+			// keep the worker which is going to stop
+			q.stopping--
+		} else {
+			c.wg.Add(1)
+			c.running.Add(1)
+			go c.queueWorker(q)
+		}
+		q.workers++
+	}
+	for q.workers > workers {
+		if q.idle > 0 {
+			q.idle--
+			select {
+			case <-c.chClose:
+			case q.chNext <- nil:
+			}
+		} else {
+			// busy worker stops after current job
+			q.stopping++
+		}
+		q.workers--
+	}
+}
+
+// pull is called by scheduler goroutine when a worker of q is ready for next job.
+func (c *Scheduler) pull(q *workQueue) {
+	if q.stopping > 0 {
+		q.stopping--
+		select {
+		case <-c.chClose:
+		case q.chNext <- nil:
+		}
+		return
+	}
+	q.idle++
+}
+
+// feed passes due jobs of all named queues to their idle workers.
+func (c *Scheduler) feed(state *scheduleState) {
+	for _, q := range state.queues {
+		for q.idle > 0 {
+			j := q.due.pop()
+			if j == nil {
+				break
+			}
+			state.release(j)
+			q.idle--
+			select {
+			case <-c.chClose:
+				return
+			case q.chNext <- j:
+			}
+		}
+	}
+}
+
+func (c *Scheduler) queueWorker(q *workQueue) {
+	defer c.wg.Done()
+	defer c.running.Done()
+	for {
+		select {
+		case <-c.chClose:
+			return
+		case c.chPull <- q:
+		}
+		// the worker is counted as idle by scheduler and must receive next job
+		select {
+		case <-c.chClose:
+			return
+		case j := <-q.chNext:
+			if j == nil {
+				return
+			}
+			j.c.run(*j)
+		}
+	}
+}
+
+// countersOf returns counters of the queue which the job belongs to.
+func (c *Scheduler) countersOf(j *job) *counters {
+	if j.q != nil {
+		return &j.q.counters
+	}
+	return &c.counters
+}
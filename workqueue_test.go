@@ -0,0 +1,114 @@
+package htask
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Queues(t *testing.T) {
+	var wg sync.WaitGroup
+	scheduler := NewSchedulerWithOption(&wg, Option{Workers: 1, Queues: map[string]int{"batch": 1, "mail": 1}})
+	defer func() {
+		scheduler.Close()
+		wg.Wait()
+	}()
+
+	if names := scheduler.Queues(); !reflect.DeepEqual(names, []string{"batch", "mail"}) {
+		t.Errorf("Queues = %v", names)
+	}
+	noop := func(ctx context.Context, info TaskInfo) error { return nil }
+	if _, err := scheduler.SetContextWithOption(nil, time.Now(), noop, TaskOption{Queue: "unknown"}); err != ErrUnknownQueue {
+		t.Errorf("SetContextWithOption unknown queue : %v expected %v", err, ErrUnknownQueue)
+	}
+	if err := scheduler.ChangeQueueWorkers("unknown", 1); err != ErrUnknownQueue {
+		t.Errorf("ChangeQueueWorkers unknown queue : %v expected %v", err, ErrUnknownQueue)
+	}
+	if _, err := scheduler.QueueStats("unknown"); err != ErrUnknownQueue {
+		t.Errorf("QueueStats unknown queue : %v expected %v", err, ErrUnknownQueue)
+	}
+
+	chRelease := make(chan struct{})
+	chResult := make(chan string, 10)
+	slow := func(ctx context.Context, info TaskInfo) error {
+		<-chRelease
+		chResult <- "batch"
+		return nil
+	}
+	waitQueue := func(name string, busy, due int) {
+		var stats Stats
+		for i := 0; i < 100; i++ {
+			if stats, _ = scheduler.QueueStats(name); stats.Busy == busy && stats.Due == due {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("queue %v : Busy = %v, Due = %v expected %v, %v", name, stats.Busy, stats.Due, busy, due)
+	}
+
+	now := time.Now()
+	scheduler.SetContextWithOption(nil, now, slow, TaskOption{Queue: "batch"})
+	scheduler.SetContextWithOption(nil, now, slow, TaskOption{Queue: "batch"})
+	waitQueue("batch", 1, 1)
+
+	// default workers and other queue are not blocked by slow queue
+	scheduler.Set(nil, now, func(_ time.Time) { chResult <- "default" })
+	scheduler.SetContextWithOption(nil, now, func(ctx context.Context, info TaskInfo) error {
+		chResult <- "mail"
+		return nil
+	}, TaskOption{Queue: "mail"})
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-chResult:
+			if r == "batch" {
+				t.Fatal("blocked task finished")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("task is blocked by other queue")
+		}
+	}
+	if stats, _ := scheduler.Stats(); stats.Pending != 1 || stats.Due != 0 || stats.Workers != 1 {
+		t.Errorf("Stats Pending = %v, Due = %v, Workers = %v expected 1, 0, 1", stats.Pending, stats.Due, stats.Workers)
+	}
+
+	if err := scheduler.ChangeQueueWorkers("batch", 2); err != nil {
+		t.Fatal(err)
+	}
+	waitQueue("batch", 2, 0)
+	close(chRelease)
+	for i := 0; i < 2; i++ {
+		if r := <-chResult; r != "batch" {
+			t.Errorf("result = %v expected batch", r)
+		}
+	}
+
+	// queue without workers holds due tasks
+	scheduler.ChangeQueueWorkers("mail", 0)
+	scheduler.SetContextWithOption(nil, time.Now(), func(ctx context.Context, info TaskInfo) error {
+		chResult <- "mail"
+		return nil
+	}, TaskOption{Queue: "mail"})
+	waitQueue("mail", 0, 1)
+	if stats, _ := scheduler.QueueStats("mail"); stats.Workers != 0 {
+		t.Errorf("mail Workers = %v expected 0", stats.Workers)
+	}
+	scheduler.ChangeQueueWorkers("mail", 1)
+	select {
+	case r := <-chResult:
+		if r != "mail" {
+			t.Errorf("result = %v expected mail", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task not executed after workers are added")
+	}
+
+	waitQueue("mail", 0, 0)
+	batch, _ := scheduler.QueueStats("batch")
+	mail, _ := scheduler.QueueStats("mail")
+	total, _ := scheduler.Stats()
+	if batch.Executed != 2 || mail.Executed != 2 || total.Executed != 5 {
+		t.Errorf("Executed batch = %v, mail = %v, total = %v expected 2, 2, 5", batch.Executed, mail.Executed, total.Executed)
+	}
+}